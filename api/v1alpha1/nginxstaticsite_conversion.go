@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// specAnnotation stores the v1beta1 spec on v1alpha1 objects so fields that
+// have no v1alpha1 equivalent survive a round trip through this version.
+const specAnnotation = "web.ictplus.ir/v1beta1-spec"
+
+// statusAnnotation does the same for the v1beta1 status, which records among
+// others the claim the site content lives on.
+const statusAnnotation = "web.ictplus.ir/v1beta1-status"
+
+// ConvertTo converts this NginxStaticSite (v1alpha1) to the Hub version (v1beta1).
+func (src *NginxStaticSite) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*webv1beta1.NginxStaticSite)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	for annotation, into := range map[string]any{specAnnotation: &dst.Spec, statusAnnotation: &dst.Status} {
+		raw, ok := dst.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(raw), into); err != nil {
+			return fmt.Errorf("decoding %s annotation: %w", annotation, err)
+		}
+		delete(dst.Annotations, annotation)
+	}
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	// Fields present in v1alpha1 always win over the preserved copy, so edits
+	// made through this version take effect.
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.Storage.Size = src.Spec.StorageSize
+	dst.Spec.Pod.ImageVersion = src.Spec.ImageVersion
+	dst.Spec.Pod.NodeSelector = src.Spec.NodeSelector
+	dst.Spec.Content.Path = src.Spec.StaticFilePath
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
+	dst.Status.Conditions = src.Status.Conditions
+
+	return nil
+}
+
+// ConvertFrom converts the Hub version (v1beta1) to this version (v1alpha1).
+func (dst *NginxStaticSite) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*webv1beta1.NginxStaticSite)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	for annotation, from := range map[string]any{specAnnotation: src.Spec, statusAnnotation: src.Status} {
+		raw, err := json.Marshal(from)
+		if err != nil {
+			return fmt.Errorf("encoding %s annotation: %w", annotation, err)
+		}
+		dst.Annotations[annotation] = string(raw)
+	}
+
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.StorageSize = src.Spec.Storage.Size
+	dst.Spec.ImageVersion = src.Spec.Pod.ImageVersion
+	dst.Spec.NodeSelector = src.Spec.Pod.NodeSelector
+	dst.Spec.StaticFilePath = src.Spec.Content.Path
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
+	dst.Status.Conditions = src.Status.Conditions
+
+	return nil
+}
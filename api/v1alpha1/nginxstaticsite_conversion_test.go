@@ -0,0 +1,116 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func hubSite() *webv1beta1.NginxStaticSite {
+	site := &webv1beta1.NginxStaticSite{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "docs",
+			Namespace:   "default",
+			Annotations: map[string]string{"team": "web"},
+		},
+	}
+	site.Spec.Replicas = ptr.To[int32](2)
+	site.Spec.Storage.Size = "1Gi"
+	site.Spec.Storage.StorageClassName = ptr.To("fast")
+	site.Spec.Pod.ImageVersion = "1.27.0"
+	site.Spec.Pod.NodeSelector = map[string]string{"disk": "ssd"}
+	site.Spec.Content.Path = "/usr/share/nginx/html"
+	site.Spec.Routing.Host = "docs.example.com"
+	site.Spec.HistoryLimit = ptr.To[int32](3)
+	site.Status.ObservedGeneration = 4
+	site.Status.ReadyReplicas = 2
+	site.Status.Conditions = []metav1.Condition{{Type: webv1beta1.ConditionReady, Status: metav1.ConditionTrue}}
+	site.Status.Storage = &webv1beta1.StorageStatus{ClaimName: "docs-pvc-2"}
+	site.Status.CurrentRevision = "docs-rev-7"
+	return site
+}
+
+func TestHubRoundTrip(t *testing.T) {
+	hub := hubSite()
+	spoke := &NginxStaticSite{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	got := &webv1beta1.NginxStaticSite{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, hub) {
+		t.Errorf("round trip through v1alpha1 changed the site:\n got %+v\nwant %+v", got, hub)
+	}
+}
+
+func TestSpokeRoundTrip(t *testing.T) {
+	spoke := &NginxStaticSite{ObjectMeta: metav1.ObjectMeta{Name: "docs", Namespace: "default"}}
+	spoke.Spec.Replicas = ptr.To[int32](1)
+	spoke.Spec.StorageSize = "2Gi"
+	spoke.Spec.ImageVersion = "1.25-alpine"
+	spoke.Spec.StaticFilePath = "/srv/www"
+	spoke.Status.ReadyReplicas = 1
+
+	hub := &webv1beta1.NginxStaticSite{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	got := &NginxStaticSite{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Spec, spoke.Spec) || !reflect.DeepEqual(got.Status, spoke.Status) {
+		t.Errorf("round trip through v1beta1 changed the site:\n got %+v\nwant %+v", got, spoke)
+	}
+	for _, annotation := range []string{specAnnotation, statusAnnotation} {
+		if _, ok := got.Annotations[annotation]; !ok {
+			t.Errorf("annotation %s missing after ConvertFrom", annotation)
+		}
+	}
+}
+
+func TestSpokeEditsWin(t *testing.T) {
+	hub := hubSite()
+	spoke := &NginxStaticSite{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	spoke.Spec.ImageVersion = "1.27.1"
+	spoke.Spec.Replicas = ptr.To[int32](5)
+
+	got := &webv1beta1.NginxStaticSite{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	want := hubSite()
+	want.Spec.Pod.ImageVersion = "1.27.1"
+	want.Spec.Replicas = ptr.To[int32](5)
+	if !reflect.DeepEqual(got.Spec, want.Spec) {
+		t.Errorf("v1alpha1 edits not applied:\n got %+v\nwant %+v", got.Spec, want.Spec)
+	}
+	if got.Status.Storage == nil || got.Status.Storage.ClaimName != "docs-pvc-2" {
+		t.Errorf("status.storage = %+v, want claimName docs-pvc-2", got.Status.Storage)
+	}
+}
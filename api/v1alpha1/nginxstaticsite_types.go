@@ -20,31 +20,54 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // NginxStaticSiteSpec defines the desired state of NginxStaticSite.
+//
+// v1alpha1 is kept for existing manifests and is converted to and from the
+// v1beta1 storage version; new fields are only added to v1beta1.
 type NginxStaticSiteSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-
-        Replicas       int32             `json:"replicas"`
-        StorageSize    string            `json:"storageSize"`
-        ImageVersion   string            `json:"imageVersion"`
-        NodeSelector   map[string]string `json:"nodeSelector,omitempty"`
-        StaticFilePath string            `json:"staticFilePath"`
+	// Replicas is the number of nginx pods. Defaulted by the mutating webhook when omitted.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// StorageSize is the requested size of the site PVC. Defaulted by the mutating webhook when omitted.
+	// +optional
+	StorageSize string `json:"storageSize,omitempty"`
+	// ImageVersion is the tag of the nginx image. Defaulted by the mutating webhook when omitted.
+	// +optional
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// NodeSelector constrains the nodes the nginx pods are scheduled on.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// StaticFilePath is where the site volume is mounted in the nginx container.
+	// Defaulted by the mutating webhook when omitted.
+	// +optional
+	StaticFilePath string `json:"staticFilePath,omitempty"`
 }
 
 // NginxStaticSiteStatus defines the observed state of NginxStaticSite.
 type NginxStaticSiteStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-	Phase         string `json:"phase,omitempty"`
-        ReadyReplicas int32  `json:"readyReplicas,omitempty"`
+	// ObservedGeneration is the most recent metadata.generation the controller acted on.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of nginx pods passing their readiness checks.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Conditions describe the current state of the site and of each child resource.
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NginxStaticSite is the Schema for the nginxstaticsites API.
 type NginxStaticSite struct {
@@ -0,0 +1,915 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NginxStaticSiteSpec defines the desired state of NginxStaticSite.
+type NginxStaticSiteSpec struct {
+	// Replicas is the number of nginx pods. Defaulted by the mutating webhook when omitted.
+	// Exposed through the scale subresource, so `kubectl scale` and a
+	// HorizontalPodAutoscaler targeting the site change this field.
+	// Ignored while Autoscaling is set.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Autoscaling makes the operator manage a HorizontalPodAutoscaler for the
+	// nginx Deployment instead of pinning it to Replicas.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Content configures the files served by the site.
+	// +optional
+	Content ContentSpec `json:"content,omitempty"`
+
+	// Source fills the site volume from an external source. The volume is
+	// left to be filled by hand when unset.
+	// +optional
+	Source *SourceSpec `json:"source,omitempty"`
+
+	// Server configures the nginx server block rendered by the operator.
+	// +optional
+	Server ServerSpec `json:"server,omitempty"`
+
+	// Storage configures the volume holding the site content.
+	// +optional
+	Storage StorageSpec `json:"storage,omitempty"`
+
+	// Routing configures how the site is exposed outside the cluster.
+	// +optional
+	Routing RoutingSpec `json:"routing,omitempty"`
+
+	// Pod configures the nginx pods.
+	// +optional
+	Pod PodSpec `json:"pod,omitempty"`
+
+	// TLS enables HTTPS on the site Ingress.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// HistoryLimit is how many NginxStaticSiteRevisions are kept for the
+	// site. Defaults to 10.
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+
+	// Release stages a new release in a second stack of the other colour next
+	// to the live one, until the web.ictplus.ir/promote annotation switches
+	// the site over to it.
+	// +optional
+	Release *ReleaseSpec `json:"release,omitempty"`
+
+	// Canary sends a growing share of the traffic to a canary Deployment
+	// running a new image version or content revision, and makes it the live
+	// spec once every step passed.
+	// +optional
+	Canary *CanarySpec `json:"canary,omitempty"`
+}
+
+// CanarySpec describes a canary rollout. At least one of ImageVersion and
+// Revision has to be set.
+type CanarySpec struct {
+	// ImageVersion is the nginx image tag of the canary. Defaults to
+	// spec.pod.imageVersion.
+	// +optional
+	ImageVersion string `json:"imageVersion,omitempty"`
+
+	// Revision is the content revision served by the canary, a directory
+	// under "revisions/" on the site volume (see spec.content.revision).
+	// Defaults to the content served by the site.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Replicas is the number of canary pods. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Steps raise the share of traffic sent to the canary one after another.
+	// +kubebuilder:validation:MinItems=1
+	Steps []CanaryStep `json:"steps"`
+
+	// MaxErrorPercentage aborts the canary when more than this percentage of
+	// the requests to its pods fail with a 5xx status. It is read from the
+	// nginx_http_requests_per_second and nginx_http_server_errors_per_second
+	// pods metrics of the custom metrics API. Not checked when unset.
+	// +optional
+	MaxErrorPercentage *int32 `json:"maxErrorPercentage,omitempty"`
+}
+
+// CanaryStep is one traffic weight of a canary rollout.
+type CanaryStep struct {
+	// Weight is the percentage of requests sent to the canary.
+	Weight int32 `json:"weight"`
+
+	// Pause is how long the step lasts before the next one. Defaults to 5m.
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+// ReleaseSpec describes the release staged in the idle stack. Fields left out
+// are taken from the live spec.
+type ReleaseSpec struct {
+	// Source is the content of the release. Defaults to spec.source.
+	// +optional
+	Source *SourceSpec `json:"source,omitempty"`
+
+	// ImageVersion is the nginx image tag of the release. Defaults to
+	// spec.pod.imageVersion.
+	// +optional
+	ImageVersion string `json:"imageVersion,omitempty"`
+
+	// Preview is where the release is served before it is promoted.
+	// +optional
+	Preview PreviewSpec `json:"preview,omitempty"`
+}
+
+// PreviewSpec exposes the staged release through its own Ingress.
+type PreviewSpec struct {
+	// Host of the preview Ingress rule. Defaults to spec.routing.host.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path is the URL prefix of the preview. Defaults to "/<site name>-<colour>",
+	// the colour of the stack the release is staged in.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler owned by the site.
+// The HPA falls back to an 80% CPU target when neither target is set.
+type AutoscalingSpec struct {
+	// MinReplicas is the lower bound on the number of nginx pods. Defaults to 1.
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the upper bound on the number of nginx pods.
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetCPUUtilizationPercentage is the average CPU usage per pod, relative
+	// to its CPU request, the HPA aims for. Requires Pod.Resources.Requests.cpu.
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// TargetRequestsPerSecond is the average request rate per pod the HPA aims
+	// for. It is read from the nginx_http_requests_per_second pods metric, which
+	// a custom metrics adapter has to serve.
+	// +optional
+	TargetRequestsPerSecond *resource.Quantity `json:"targetRequestsPerSecond,omitempty"`
+}
+
+// ContentSpec configures the files served by the site.
+type ContentSpec struct {
+	// Path is where the site volume is mounted in the nginx container.
+	// Defaulted by the mutating webhook when omitted.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Revision pins the served revision to a directory under "revisions/" on
+	// the site volume, for example to roll back to an entry of
+	// status.content.history. S3 and archive sources publish every new
+	// revision while it is empty. Without a source, setting it publishes
+	// content uploaded to "revisions/<revision>".
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// RevisionHistoryLimit is how many revisions are kept on the site
+	// volume, besides the one being served. Defaults to 5.
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// SourceSpec selects where the site content comes from.
+type SourceSpec struct {
+	// Git keeps the content at a ref of a git repository.
+	// +optional
+	Git *GitSource `json:"git,omitempty"`
+
+	// S3 syncs the content from an S3-compatible bucket.
+	// +optional
+	S3 *S3Source `json:"s3,omitempty"`
+
+	// Archive extracts the content from a tarball or zip file behind a URL.
+	// +optional
+	Archive *ArchiveSource `json:"archive,omitempty"`
+
+	// Image takes the content from a container image or OCI artifact.
+	// +optional
+	Image *ImageSource `json:"image,omitempty"`
+
+	// ConfigMapRef projects the keys of a ConfigMap in the site namespace as
+	// files into Content.Path, without a PVC. Edits to the ConfigMap roll the
+	// pods. Meant for small sites such as maintenance pages.
+	// +optional
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// SecretRef projects the keys of a Secret in the site namespace like
+	// ConfigMapRef, for content that should only be readable by those
+	// allowed to read Secrets.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// GitSource syncs the content from a git repository with git-sync. The
+// repository is checked out under "current" in the site volume, which the
+// rendered server block serves by default.
+type GitSource struct {
+	// URL of the repository, over HTTPS or SSH.
+	URL string `json:"url"`
+
+	// Ref is the branch, tag or commit to check out. Defaults to HEAD.
+	// +optional
+	Ref string `json:"ref,omitempty"`
+
+	// Subdirectory of the repository to serve. The repository root is served when empty.
+	// +optional
+	Subdirectory string `json:"subdirectory,omitempty"`
+
+	// CredentialsSecretRef names a Secret in the site namespace holding
+	// "username" and "password" for HTTPS, or "ssh-privatekey" and
+	// "known_hosts" for SSH.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+
+	// PollInterval is how often the repository is checked for new commits. Defaults to 1m.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// S3Source syncs the content from an S3-compatible bucket with an rclone Job.
+// A sync runs when the source changes, every SyncInterval, and whenever the
+// web.ictplus.ir/sync-requested-at annotation of the site changes.
+type S3Source struct {
+	// Endpoint is the URL of the S3 API, e.g. https://minio.example.com.
+	// AWS is used when empty.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region of the bucket.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Bucket holding the content.
+	Bucket string `json:"bucket"`
+
+	// Prefix of the objects to serve. The whole bucket is served when empty.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// CredentialsSecretRef names a Secret in the site namespace holding
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+
+	// SyncInterval re-syncs the bucket periodically. Content is only synced on
+	// changes and on demand when unset.
+	// +optional
+	SyncInterval *metav1.Duration `json:"syncInterval,omitempty"`
+}
+
+// ArchiveSource extracts a .tar(.gz|.bz2|.xz) or .zip file into the site
+// volume with a Job. The archive is only published when its checksum matches;
+// until a new archive is extracted the previous one keeps being served.
+type ArchiveSource struct {
+	// URL of the archive, over HTTP or HTTPS.
+	URL string `json:"url"`
+
+	// SHA256 is the expected hex digest of the archive.
+	// +kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	SHA256 string `json:"sha256"`
+
+	// StripComponents removes that many leading directories, each of which
+	// must be the only entry at its level.
+	// +optional
+	StripComponents int32 `json:"stripComponents,omitempty"`
+}
+
+// Ways of mounting an image source.
+const (
+	// ImageMountCopy copies the content out of the image with an init container.
+	ImageMountCopy = "Copy"
+	// ImageMountVolume mounts the image as a read-only image volume.
+	ImageMountVolume = "ImageVolume"
+)
+
+// ImageSource serves the content of a container image instead of the site
+// PVC. The image is referenced by digest once it was first pulled, so every
+// replica serves the same content even when the tag moves.
+type ImageSource struct {
+	// Reference of the image, by tag or digest.
+	Reference string `json:"reference"`
+
+	// Path is the directory inside the image holding the site. Defaults to "/".
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// PullSecrets name Secrets in the site namespace used to pull the image.
+	// +optional
+	PullSecrets []corev1.LocalObjectReference `json:"pullSecrets,omitempty"`
+
+	// Mount is Copy (the default) to copy Path into an emptyDir with an init
+	// container running the image, which needs a cp binary in it, or
+	// ImageVolume to mount the image directly, which needs a cluster with
+	// image volumes enabled.
+	// +optional
+	Mount string `json:"mount,omitempty"`
+}
+
+// ServerSpec configures the nginx server block rendered into the site ConfigMap.
+type ServerSpec struct {
+	// Root is the document root. Defaults to Content.Path; set it to serve a
+	// subdirectory of the content.
+	// +optional
+	Root string `json:"root,omitempty"`
+
+	// Index lists the index files tried for directory requests.
+	// Defaults to ["index.html", "index.htm"].
+	// +optional
+	Index []string `json:"index,omitempty"`
+
+	// TryFiles is the try_files fallback chain, for example
+	// ["$uri", "$uri/", "/index.html"] for single-page apps.
+	// Defaults to ["$uri", "$uri/", "=404"].
+	// +optional
+	TryFiles []string `json:"tryFiles,omitempty"`
+
+	// Gzip enables compression of responses.
+	// +optional
+	Gzip *GzipSpec `json:"gzip,omitempty"`
+
+	// Expires sets the expires directive, e.g. "1h", "30d", "max" or "off".
+	// +optional
+	Expires string `json:"expires,omitempty"`
+
+	// Headers are added to every response.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SnippetRef points at a ConfigMap key holding raw directives appended to
+	// the rendered server block. The result is checked with nginx -t before
+	// it is rolled out.
+	// +optional
+	SnippetRef *ConfigMapKeyReference `json:"snippetRef,omitempty"`
+}
+
+// ConfigMapKeyReference selects a key of a ConfigMap in the site namespace.
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Key in the ConfigMap. Defaults to "snippet.conf".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// GzipSpec configures gzip compression.
+type GzipSpec struct {
+	// Types are the MIME types compressed in addition to text/html.
+	// +optional
+	Types []string `json:"types,omitempty"`
+
+	// MinLength is the smallest response, in bytes, that is compressed.
+	// +optional
+	MinLength *int32 `json:"minLength,omitempty"`
+}
+
+// Storage modes of the site volume.
+const (
+	// StorageModePVC keeps the content on a ReadWriteOnce PVC shared by all replicas.
+	StorageModePVC = "pvc"
+	// StorageModeEmptyDir gives every replica an emptyDir it fetches the content into at startup.
+	StorageModeEmptyDir = "emptyDir"
+	// StorageModeReadOnlyMany mounts a pre-populated ReadOnlyMany PVC read-only.
+	StorageModeReadOnlyMany = "readOnlyMany"
+)
+
+// StorageSpec configures the volume holding the site content.
+type StorageSpec struct {
+	// Size is the requested size of the site PVC, or the size limit of the
+	// emptyDir in emptyDir mode. Defaulted by the mutating webhook when omitted.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// Mode is "pvc" (the default) for a ReadWriteOnce PVC, which ties all
+	// replicas to one node; "emptyDir" for a per-replica emptyDir filled from
+	// spec.source at startup; or "readOnlyMany" to mount existingClaim, a
+	// ReadOnlyMany PVC whose content is provisioned outside the operator.
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// StorageClassName of the site PVC. The cluster default class is used when empty.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes of the site PVC. Defaults to ReadWriteOnce, or to
+	// ReadOnlyMany in readOnlyMany mode.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// VolumeMode of the site PVC. Only Filesystem, the default, is supported,
+	// as nginx serves the content from a mounted file system.
+	// +optional
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+
+	// ExistingClaim names a pre-provisioned PVC in the site namespace to serve
+	// instead of creating "<name>-pvc". The operator never resizes or deletes it.
+	// +optional
+	ExistingClaim string `json:"existingClaim,omitempty"`
+
+	// DeletionPolicy decides what happens to the site PVC when the site is
+	// deleted: Delete (the default) removes it, Retain leaves it behind
+	// without an owner, and Snapshot takes a VolumeSnapshot of it before
+	// removing it.
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// AllowMigration lets the operator move the content to a new PVC when
+	// the size shrinks or the storage class or access modes change, which
+	// cannot be done in place. The old claim is deleted once the pods moved.
+	// Without it such changes are rejected.
+	// +optional
+	AllowMigration bool `json:"allowMigration,omitempty"`
+}
+
+// Deletion policies of the site PVC.
+const (
+	DeletionPolicyDelete   = "Delete"
+	DeletionPolicyRetain   = "Retain"
+	DeletionPolicySnapshot = "Snapshot"
+)
+
+// RoutingSpec configures how the site is exposed outside the cluster.
+// +kubebuilder:validation:XValidation:rule="!has(self.type) || self.type != 'gatewayAPI' || has(self.gateway)",message="gateway is required for gatewayAPI routing"
+type RoutingSpec struct {
+	// Type selects how the site is exposed: "ingress" (the default) for a
+	// networking/v1 Ingress, or "gatewayAPI" for a Gateway API HTTPRoute
+	// attached to Gateway.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Gateway is the Gateway the HTTPRoute attaches to. Required when Type
+	// is gatewayAPI.
+	// +optional
+	Gateway *GatewayReference `json:"gateway,omitempty"`
+
+	// Host restricts the Ingress rule to a single host name. All hosts match when empty.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path is the URL prefix the site is served under. Defaults to "/<site name>".
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// IngressClassName selects the ingress controller. The cluster default is used when empty.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+}
+
+// Routing types.
+const (
+	RoutingTypeIngress    = "ingress"
+	RoutingTypeGatewayAPI = "gatewayAPI"
+)
+
+// GatewayReference names the Gateway an HTTPRoute attaches to.
+type GatewayReference struct {
+	// Name of the Gateway.
+	Name string `json:"name"`
+
+	// Namespace of the Gateway. Defaults to the site namespace. The Gateway
+	// listener has to allow routes from the site namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName attaches the route to a single listener of the Gateway.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// PodSpec configures the nginx pods.
+type PodSpec struct {
+	// ImageVersion is the tag of the nginx image. Defaulted by the mutating webhook when omitted.
+	// +optional
+	ImageVersion string `json:"imageVersion,omitempty"`
+
+	// NodeSelector constrains the nodes the nginx pods are scheduled on.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Resources are the compute requests and limits of the nginx container.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// TLSSpec enables HTTPS on the site Ingress.
+type TLSSpec struct {
+	// SecretName is the kubernetes.io/tls Secret holding the certificate for Routing.Host.
+	SecretName string `json:"secretName"`
+}
+
+// Condition types reported on NginxStaticSiteStatus.Conditions.
+const (
+	// ConditionReady is True once every child resource is reconciled and the
+	// site is serving traffic with the requested number of replicas.
+	ConditionReady = "Ready"
+	// ConditionStorageReady reports whether the site PVC exists and is bound.
+	ConditionStorageReady = "StorageReady"
+	// ConditionDeploymentAvailable mirrors the Available condition of the nginx Deployment.
+	ConditionDeploymentAvailable = "DeploymentAvailable"
+	// ConditionServiceReady reports whether the site Service is reconciled.
+	ConditionServiceReady = "ServiceReady"
+	// ConditionIngressReady reports whether the site Ingress is reconciled.
+	ConditionIngressReady = "IngressReady"
+	// ConditionDegraded is True when the last reconcile failed.
+	ConditionDegraded = "Degraded"
+	// ConditionContentReady reports whether the content of a Job-synced
+	// source is in place on the site volume.
+	ConditionContentReady = "ContentReady"
+	// ConditionConfigInvalid is True when the nginx config with the server
+	// snippet was rejected, in which case the previous config keeps running.
+	ConditionConfigInvalid = "ConfigInvalid"
+	// ConditionStorageResizing is True while the site PVC is being expanded
+	// to the requested size.
+	ConditionStorageResizing = "StorageResizing"
+	// ConditionStorageMigrating is True while the content is moved to a new PVC.
+	ConditionStorageMigrating = "StorageMigrating"
+	// ConditionRouteAccepted mirrors the Accepted condition the Gateway
+	// reports on the site HTTPRoute.
+	ConditionRouteAccepted = "RouteAccepted"
+	// ConditionRouteResolvedRefs mirrors the ResolvedRefs condition the
+	// Gateway reports on the site HTTPRoute.
+	ConditionRouteResolvedRefs = "RouteResolvedRefs"
+)
+
+// Condition reasons reported on NginxStaticSiteStatus.Conditions.
+const (
+	ReasonReconciling               = "Reconciling"
+	ReasonReconciled                = "Reconciled"
+	ReasonAsExpected                = "AsExpected"
+	ReasonPVCBound                  = "PVCBound"
+	ReasonPVCPending                = "PVCPending"
+	ReasonPVCFailed                 = "PVCFailed"
+	ReasonDeploymentAvailable       = "DeploymentAvailable"
+	ReasonDeploymentProgressing     = "DeploymentProgressing"
+	ReasonDeploymentFailed          = "DeploymentFailed"
+	ReasonServiceFailed             = "ServiceFailed"
+	ReasonIngressFailed             = "IngressFailed"
+	ReasonPodsNotReady              = "PodsNotReady"
+	ReasonFinalizerFailed           = "FinalizerFailed"
+	ReasonAutoscalerFailed          = "AutoscalerFailed"
+	ReasonConfigFailed              = "ConfigFailed"
+	ReasonConfigValidating          = "ConfigValidating"
+	ReasonNginxTestFailed           = "NginxTestFailed"
+	ReasonSnippetNotFound           = "SnippetNotFound"
+	ReasonSyncing                   = "Syncing"
+	ReasonSynced                    = "Synced"
+	ReasonSyncFailed                = "SyncFailed"
+	ReasonExtracting                = "Extracting"
+	ReasonExtracted                 = "Extracted"
+	ReasonExtractFailed             = "ExtractFailed"
+	ReasonContentNotFound           = "ContentNotFound"
+	ReasonProjected                 = "Projected"
+	ReasonResizeRequested           = "ResizeRequested"
+	ReasonResizing                  = "Resizing"
+	ReasonFileSystemResizePending   = "FileSystemResizePending"
+	ReasonResized                   = "Resized"
+	ReasonStorageShrinkNotSupported = "StorageShrinkNotSupported"
+	ReasonSnapshotFailed            = "SnapshotFailed"
+	ReasonMigrationCompleted        = "MigrationCompleted"
+	ReasonMigrationCancelled        = "MigrationCancelled"
+	ReasonMigrationFailed           = "MigrationFailed"
+	ReasonRevisionPublished         = "RevisionPublished"
+	ReasonRevisionFailed            = "RevisionFailed"
+	ReasonHistoryFailed             = "HistoryFailed"
+	ReasonReleaseFailed             = "ReleaseFailed"
+	ReasonCanaryFailed              = "CanaryFailed"
+	ReasonRouteFailed               = "RouteFailed"
+	ReasonRoutePending              = "RoutePending"
+	ReasonGatewayMissing            = "GatewayMissing"
+)
+
+// NginxStaticSiteStatus defines the observed state of NginxStaticSite.
+type NginxStaticSiteStatus struct {
+	// ObservedGeneration is the most recent metadata.generation the controller acted on.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of nginx pods passing their readiness checks.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Selector is the label selector of the nginx pods, published for the
+	// scale subresource so a HorizontalPodAutoscaler can find them.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// ConfigChecksum is the sha256 of the nginx config currently rolled out.
+	// +optional
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+
+	// TestedConfigChecksum is the sha256 of the image and nginx config last
+	// accepted by nginx -t. A server snippet is tested again when it changes.
+	// +optional
+	TestedConfigChecksum string `json:"testedConfigChecksum,omitempty"`
+
+	// Source reports the content currently served from spec.source.
+	// +optional
+	Source *SourceStatus `json:"source,omitempty"`
+
+	// Content reports the content revisions on the site volume.
+	// +optional
+	Content *ContentStatus `json:"content,omitempty"`
+
+	// Storage reports the PVC serving the site and any migration to a new one.
+	// +optional
+	Storage *StorageStatus `json:"storage,omitempty"`
+
+	// ActiveColor is the stack serving the site: "blue" for the children
+	// named after the site, "green" for the "<name>-green" ones. Empty means
+	// blue. Promoting a release switches it to the other colour.
+	// +optional
+	ActiveColor string `json:"activeColor,omitempty"`
+
+	// Release reports the release while one is staged or promoted.
+	// +optional
+	Release *ReleaseStatus `json:"release,omitempty"`
+
+	// Canary reports the last canary rollout.
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// CurrentRevision is the NginxStaticSiteRevision recording the spec and
+	// content currently rolled out.
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// Conditions describe the current state of the site and of each child resource.
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Phases of a canary.
+const (
+	CanaryProgressing = "Progressing"
+	CanaryPromoted    = "Promoted"
+	CanaryAborted     = "Aborted"
+)
+
+// CanaryStatus reports the last canary rollout.
+type CanaryStatus struct {
+	// Phase is Progressing while the steps run, then Promoted or Aborted.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// Step is the index of the current step in spec.canary.steps.
+	// +optional
+	Step int32 `json:"step"`
+
+	// Weight is the percentage of requests currently sent to the canary.
+	// +optional
+	Weight int32 `json:"weight"`
+
+	// StepStartTime is when the current step started.
+	// +optional
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+
+	// Message explains the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// SpecHash identifies the spec.canary the rollout runs, so changing it
+	// starts over.
+	// +optional
+	SpecHash string `json:"specHash,omitempty"`
+}
+
+// Colours of the two stacks a release alternates between.
+const (
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+)
+
+// Phases of a release.
+const (
+	ReleaseStaging  = "Staging"
+	ReleaseReady    = "Ready"
+	ReleasePromoted = "Promoted"
+)
+
+// ReleaseStatus reports the release staged in the idle stack.
+type ReleaseStatus struct {
+	// Phase is Staging while the idle Deployment rolls out and fetches its
+	// content, Ready once it can be promoted, and Promoted once it serves the
+	// site, until the previous stack is deleted.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ReadyReplicas is the number of release pods passing their readiness checks.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// PromoteTime is when the site was switched over to the release.
+	// +optional
+	PromoteTime *metav1.Time `json:"promoteTime,omitempty"`
+
+	// Message explains the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Source reports the content served by the release Deployment.
+	// +optional
+	Source *SourceStatus `json:"source,omitempty"`
+
+	// Content reports the content revisions on the release volume.
+	// +optional
+	Content *ContentStatus `json:"content,omitempty"`
+
+	// ConfigChecksum is the sha256 of the nginx config of the release Deployment.
+	// +optional
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+}
+
+// ContentStatus reports the content revisions on the site volume.
+type ContentStatus struct {
+	// Revision is the revision being served.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// History lists the revisions kept on the site volume, newest first.
+	// +optional
+	History []ContentRevision `json:"history,omitempty"`
+}
+
+// ContentRevision is a complete copy of the content in "revisions/<name>".
+type ContentRevision struct {
+	// Name of the revision directory.
+	Name string `json:"name"`
+
+	// CreationTime is when the revision was written.
+	// +optional
+	CreationTime metav1.Time `json:"creationTime,omitempty"`
+}
+
+// StorageStatus reports the PVC serving the site.
+type StorageStatus struct {
+	// ClaimName is the PVC the site is served from. Empty means "<name>-pvc".
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Migration tracks the move to a new PVC, if one is in progress.
+	// +optional
+	Migration *StorageMigrationStatus `json:"migration,omitempty"`
+}
+
+// Phases of a storage migration.
+const (
+	// MigrationCopying is copying the content to the new claim with a Job.
+	MigrationCopying = "Copying"
+	// MigrationSwitching is waiting for the pods to move to the new claim
+	// before the old one is deleted.
+	MigrationSwitching = "Switching"
+	// MigrationFailed means the copy Job failed; the old claim keeps serving.
+	MigrationFailed = "Failed"
+)
+
+// StorageMigrationStatus tracks the move of the content to a new PVC.
+type StorageMigrationStatus struct {
+	// Phase is Copying, Switching or Failed.
+	Phase string `json:"phase"`
+
+	// SourceClaimName is the PVC being migrated from.
+	SourceClaimName string `json:"sourceClaimName"`
+
+	// TargetClaimName is the PVC being migrated to.
+	TargetClaimName string `json:"targetClaimName"`
+
+	// StartTime is when the migration started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Message describes the current phase, or why the copy failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SourceStatus reports the content currently served from spec.source.
+type SourceStatus struct {
+	// Git reports the last git-sync of the site.
+	// +optional
+	Git *GitSourceStatus `json:"git,omitempty"`
+
+	// S3 reports the last sync from the bucket.
+	// +optional
+	S3 *S3SourceStatus `json:"s3,omitempty"`
+
+	// Archive reports the archive being served.
+	// +optional
+	Archive *ArchiveSourceStatus `json:"archive,omitempty"`
+
+	// Image reports the image being served.
+	// +optional
+	Image *ImageSourceStatus `json:"image,omitempty"`
+}
+
+// S3SourceStatus reports the last sync from the bucket.
+type S3SourceStatus struct {
+	// ObjectCount is the number of files synced.
+	// +optional
+	ObjectCount int64 `json:"objectCount,omitempty"`
+
+	// Bytes is the total size of the files synced.
+	// +optional
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// LastSyncTime is when the last successful sync finished.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// LastError is the output of the last failed sync. Cleared by a successful one.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// GitSourceStatus reports the last git-sync of the site.
+type GitSourceStatus struct {
+	// Commit is the SHA of the commit being served.
+	// +optional
+	Commit string `json:"commit,omitempty"`
+
+	// LastSyncTime is when that commit was checked out.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// ArchiveSourceStatus reports the archive being served.
+type ArchiveSourceStatus struct {
+	// SHA256 is the digest of the archive being served.
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+
+	// ExtractTime is when that archive was extracted.
+	// +optional
+	ExtractTime *metav1.Time `json:"extractTime,omitempty"`
+}
+
+// ImageSourceStatus reports the image being served.
+type ImageSourceStatus struct {
+	// Reference is spec.source.image.reference as last resolved.
+	// +optional
+	Reference string `json:"reference,omitempty"`
+
+	// Digest is the digest the reference resolved to, which the pods are pinned to.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.readyReplicas,selectorpath=.status.selector
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NginxStaticSite is the Schema for the nginxstaticsites API.
+type NginxStaticSite struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NginxStaticSiteSpec   `json:"spec,omitempty"`
+	Status NginxStaticSiteStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NginxStaticSiteList contains a list of NginxStaticSite.
+type NginxStaticSiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NginxStaticSite `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NginxStaticSite{}, &NginxStaticSiteList{})
+}
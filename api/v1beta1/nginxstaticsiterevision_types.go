@@ -0,0 +1,79 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NginxStaticSiteRevisionSpec records what a site served from a point in time.
+// Revisions are written by the operator and not meant to be edited.
+type NginxStaticSiteRevisionSpec struct {
+	// SiteName is the NginxStaticSite the revision belongs to.
+	SiteName string `json:"siteName"`
+
+	// Revision numbers the revisions of a site, starting at 1.
+	Revision int64 `json:"revision"`
+
+	// SpecHash is the sha256 of the site spec, leaving out the replica count.
+	SpecHash string `json:"specHash"`
+
+	// ContentDigest identifies the content served: the git commit, the
+	// image digest, the content revision on the site volume or the checksum
+	// of the content ConfigMap. Empty for content filled by hand.
+	// +optional
+	ContentDigest string `json:"contentDigest,omitempty"`
+
+	// Image is the nginx image the site ran.
+	Image string `json:"image"`
+
+	// Timestamp is when the site started serving the revision.
+	Timestamp metav1.Time `json:"timestamp"`
+
+	// Spec is the site spec, restored by the web.ictplus.ir/rollback-to
+	// annotation on the site.
+	Spec NginxStaticSiteSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Site",type=string,JSONPath=`.spec.siteName`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.spec.revision`
+// +kubebuilder:printcolumn:name="Content",type=string,JSONPath=`.spec.contentDigest`
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NginxStaticSiteRevision is the Schema for the nginxstaticsiterevisions API.
+// The operator writes one each time the spec or the content of a site changes.
+type NginxStaticSiteRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NginxStaticSiteRevisionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NginxStaticSiteRevisionList contains a list of NginxStaticSiteRevision.
+type NginxStaticSiteRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NginxStaticSiteRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NginxStaticSiteRevision{}, &NginxStaticSiteRevisionList{})
+}
@@ -29,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -38,7 +39,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	webv1alpha1 "github.com/m-nik/k8s-nginx-operator/api/v1alpha1"
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
 	"github.com/m-nik/k8s-nginx-operator/internal/controller"
+	webhookwebv1beta1 "github.com/m-nik/k8s-nginx-operator/internal/webhook/v1beta1"
 	// "net/http"
     //     "github.com/prometheus/client_golang/prometheus/promhttp"
 	// +kubebuilder:scaffold:imports
@@ -59,6 +62,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(webv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(webv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -72,6 +76,8 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
+	var siteDefaults webhookwebv1beta1.NginxStaticSiteDefaults
+	var defaultReplicas int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -89,11 +95,20 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.IntVar(&defaultReplicas, "default-replicas", 1,
+		"Replicas applied to NginxStaticSites that omit spec.replicas.")
+	flag.StringVar(&siteDefaults.StorageSize, "default-storage-size", "1Gi",
+		"Storage size applied to NginxStaticSites that omit spec.storage.size.")
+	flag.StringVar(&siteDefaults.ImageVersion, "default-image-version", "1.25-alpine",
+		"nginx image tag applied to NginxStaticSites that omit spec.pod.imageVersion.")
+	flag.StringVar(&siteDefaults.StaticFilePath, "default-static-file-path", "/usr/share/nginx/html",
+		"Content path applied to NginxStaticSites that omit spec.content.path.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
+	siteDefaults.Replicas = int32(defaultReplicas)
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
@@ -210,13 +225,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	coreClient, err := corev1client.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create core client")
+		os.Exit(1)
+	}
 	if err = (&controller.NginxStaticSiteReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		PodProxy:      coreClient,
+		Recorder:      mgr.GetEventRecorderFor("nginxstaticsite-controller"),
+		CustomMetrics: coreClient.RESTClient(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NginxStaticSite")
 		os.Exit(1)
 	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = siteDefaults.Validate(); err != nil {
+			setupLog.Error(err, "invalid NginxStaticSite defaults")
+			os.Exit(1)
+		}
+		if err = webhookwebv1beta1.SetupNginxStaticSiteWebhookWithManager(mgr, siteDefaults); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NginxStaticSite")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -0,0 +1,189 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+const (
+	archiveImage = "alpine:3.20"
+	// archiveSiteLabel marks the extract Jobs of a site so stale ones can be removed.
+	archiveSiteLabel = "web.ictplus.ir/archive-of"
+)
+
+// archiveExtractScript downloads and verifies the archive and extracts it
+// into a revision named after its checksum, which is published unless a
+// revision is pinned. An archive extracted before is only published again.
+const archiveExtractScript = `set -eu
+` + revisionFunctions + `
+mkdir -p ` + contentJobMountPath + "/" + revisionsDir + `
+if [ ! -d "` + contentJobMountPath + "/" + revisionsDir + `/$REVISION" ]; then
+  tmp=` + contentJobMountPath + `/.download
+  rm -rf "$tmp" && mkdir -p "$tmp/x"
+  wget -q -O "$tmp/archive" "$ARCHIVE_URL"
+  if ! echo "$ARCHIVE_SHA256  $tmp/archive" | sha256sum -c -s; then
+    echo "checksum mismatch: got $(sha256sum "$tmp/archive" | cut -d' ' -f1), want $ARCHIVE_SHA256" >&2
+    rm -rf "$tmp"
+    exit 1
+  fi
+  case "$ARCHIVE_URL" in
+    *.zip|*.zip\?*) unzip -q "$tmp/archive" -d "$tmp/x" ;;
+    *) tar -xf "$tmp/archive" -C "$tmp/x" ;;
+  esac
+  src="$tmp/x"
+  i=0
+  while [ "$i" -lt "$STRIP_COMPONENTS" ]; do
+    entries=$(find "$src" -mindepth 1 -maxdepth 1 | wc -l)
+    dir=$(find "$src" -mindepth 1 -maxdepth 1 -type d)
+    if [ "$entries" -ne 1 ] || [ -z "$dir" ]; then
+      echo "cannot strip component $((i + 1)): expected a single directory" >&2
+      exit 1
+    fi
+    src="$dir"
+    i=$((i + 1))
+  done
+  mv "$src" "` + contentJobMountPath + "/" + revisionsDir + `/$REVISION"
+  rm -rf "$tmp"
+fi
+if [ "$PUBLISH" = true ]; then
+  publish "$REVISION"
+fi
+prune
+report > /dev/termination-log
+`
+
+// archiveSource returns spec.source.archive, or nil when the site is not served from an archive.
+func archiveSource(site *webv1beta1.NginxStaticSite) *webv1beta1.ArchiveSource {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.Archive
+}
+
+// reconcileArchive runs the extract Job of the current archive, removes the
+// Jobs of previous ones and reports ContentReady from its outcome.
+func (r *NginxStaticSiteReconciler) reconcileArchive(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	archive := archiveSource(site)
+	if archive == nil || fetchesAtStartup(site) {
+		if site.Status.Source != nil {
+			site.Status.Source.Archive = nil
+		}
+		return r.deleteSiteJobs(ctx, site, archiveSiteLabel, "")
+	}
+
+	job := desiredArchiveJob(site)
+	if err := r.applyContentJob(ctx, site, job); err != nil {
+		return err
+	}
+	if err := r.deleteSiteJobs(ctx, site, archiveSiteLabel, job.Name); err != nil {
+		return err
+	}
+
+	finishedAt, failed := jobFinished(job)
+	switch {
+	case finishedAt == nil:
+		// The previous archive, if any, keeps being served meanwhile.
+		if site.Status.Source == nil || site.Status.Source.Archive == nil {
+			setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonExtracting,
+				"Extracting "+archive.URL)
+		}
+	case failed:
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonExtractFailed,
+			r.jobOutput(ctx, job, "extract Job failed"))
+	default:
+		if site.Status.Source == nil {
+			site.Status.Source = &webv1beta1.SourceStatus{}
+		}
+		var seen *metav1.Time
+		if status := site.Status.Source.Archive; status != nil {
+			seen = status.ExtractTime
+		}
+		if err := updateJobContentStatus(site, seen, finishedAt, r.jobOutput(ctx, job, "")); err != nil {
+			return err
+		}
+		site.Status.Source.Archive = &webv1beta1.ArchiveSourceStatus{SHA256: archive.SHA256, ExtractTime: finishedAt}
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionTrue, webv1beta1.ReasonExtracted,
+			fmt.Sprintf("Serving %s (sha256 %s)", archive.URL, archive.SHA256))
+	}
+	return nil
+}
+
+// archiveRevision names the revision an archive is extracted into.
+func archiveRevision(archive *webv1beta1.ArchiveSource) string {
+	return "archive-" + archive.SHA256[:12]
+}
+
+// desiredArchiveJob builds the Job extracting the archive into the site volume.
+// It is named after the archive, the revision settings and the sync request
+// annotation, so changing any runs a new Job and a failed one can be retried
+// on demand.
+func desiredArchiveJob(site *webv1beta1.NginxStaticSite) *batchv1.Job {
+	archive := site.Spec.Source.Archive
+	hash := configChecksum(fmt.Sprintf("%s\n%s\n%d\n%s\n%d\n%s",
+		archive.URL, archive.SHA256, archive.StripComponents, site.Spec.Content.Revision,
+		revisionHistoryLimit(site), site.Annotations[syncRequestedAnnotation]))
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stackName(site) + "-archive-" + hash[:10],
+			Namespace: site.Namespace,
+			Labels:    map[string]string{archiveSiteLabel: stackName(site)},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{archiveExtractContainer(site, "extract")},
+					Volumes:       []corev1.Volume{contentVolume(site)},
+				},
+			},
+		},
+	}
+}
+
+// archiveExtractContainer builds the container fetching and extracting the
+// archive into the site volume, run by the extract Job or, in emptyDir mode,
+// as an init container.
+func archiveExtractContainer(site *webv1beta1.NginxStaticSite, name string) corev1.Container {
+	archive := site.Spec.Source.Archive
+	return corev1.Container{
+		Name:    name,
+		Image:   archiveImage,
+		Command: []string{"sh", "-c", archiveExtractScript},
+		Env: append(revisionEnv(site), []corev1.EnvVar{
+			{Name: "ARCHIVE_URL", Value: archive.URL},
+			{Name: "ARCHIVE_SHA256", Value: archive.SHA256},
+			{Name: "REVISION", Value: archiveRevision(archive)},
+			{Name: "STRIP_COMPONENTS", Value: strconv.Itoa(int(archive.StripComponents))},
+		}...),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "static-content", MountPath: contentJobMountPath},
+		},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// A canary runs a "<name>-canary" stack copy of the site next to the live
+// Deployment, serving the live content. The step weight of the requests is
+// sent to it by a canary Ingress for the same host and path, or by a second
+// backend of the site HTTPRoute. Once every step passed, the canary becomes
+// the live spec.
+const (
+	canarySuffix       = "-canary"
+	defaultCanaryPause = 5 * time.Minute
+	// serverErrorsPerSecondMetric is the pods metric of the 5xx responses
+	// checked against spec.canary.maxErrorPercentage.
+	serverErrorsPerSecondMetric = "nginx_http_server_errors_per_second"
+	// ingress-nginx annotations making an Ingress the canary of another one
+	// with the same host and path.
+	ingressCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	ingressCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+// Reasons of the Events emitted for a canary.
+const (
+	eventCanaryStarted  = "CanaryStarted"
+	eventCanaryStep     = "CanaryStep"
+	eventCanaryPromoted = "CanaryPromoted"
+	eventCanaryAborted  = "CanaryAborted"
+)
+
+// canarySite returns the site as seen by its canary stack. On a claim the
+// canary mounts the live one without writing to it, so instead of running the
+// source it serves the live content root or the canary revision.
+func canarySite(site *webv1beta1.NginxStaticSite) *webv1beta1.NginxStaticSite {
+	canary := stackSite(site, canarySuffix)
+	spec := &canary.Spec
+	cs := site.Spec.Canary
+	spec.Replicas = ptr.To(ptr.Deref(cs.Replicas, 1))
+	if cs.ImageVersion != "" {
+		spec.Pod.ImageVersion = cs.ImageVersion
+	}
+	spec.Routing.Path = routingPath(site)
+	if usesPVC(site) {
+		if spec.Server.Root == "" {
+			spec.Server.Root = contentRoot(site)
+			if cs.Revision != "" {
+				spec.Server.Root = path.Join(site.Spec.Content.Path, revisionsDir, cs.Revision)
+			}
+		}
+		spec.Source = nil
+		spec.Content.Revision = ""
+		spec.Storage.ExistingClaim = contentClaimName(site)
+		spec.Storage.StorageClassName = nil
+		spec.Storage.AccessModes = nil
+	}
+	canary.Status.Source = site.Status.Source.DeepCopy()
+	return canary
+}
+
+// canaryHash identifies a spec.canary, so changing it starts a new rollout.
+func canaryHash(cs *webv1beta1.CanarySpec) string {
+	data, _ := json.Marshal(cs)
+	return configChecksum(string(data))[:16]
+}
+
+// canaryPause returns how long a step lasts.
+func canaryPause(step webv1beta1.CanaryStep) time.Duration {
+	if step.Pause == nil {
+		return defaultCanaryPause
+	}
+	return step.Pause.Duration
+}
+
+// reconcileCanary walks spec.canary through its steps. It returns when the
+// current step ends, and reports true when it updated the site spec on
+// promotion, ending this reconcile.
+func (r *NginxStaticSiteReconciler) reconcileCanary(ctx context.Context, site *webv1beta1.NginxStaticSite) (time.Duration, bool, error) {
+	cs := site.Spec.Canary
+	status := site.Status.Canary
+	if cs == nil {
+		if status != nil && status.Phase == webv1beta1.CanaryProgressing {
+			if err := r.retireCanary(ctx, site); err != nil {
+				return 0, false, err
+			}
+			status.Phase = webv1beta1.CanaryAborted
+			status.Weight = 0
+			status.Message = "Removed from the spec"
+			r.event(site, corev1.EventTypeNormal, eventCanaryAborted, "Canary removed from the spec")
+		}
+		return 0, false, nil
+	}
+
+	hash := canaryHash(cs)
+	if status == nil || status.SpecHash != hash {
+		status = &webv1beta1.CanaryStatus{Phase: webv1beta1.CanaryProgressing, SpecHash: hash}
+		site.Status.Canary = status
+		r.event(site, corev1.EventTypeNormal, eventCanaryStarted, "Starting the canary in "+deploymentName(canarySite(site)))
+	}
+	switch status.Phase {
+	case webv1beta1.CanaryPromoted:
+		// The spec update of the promotion did not go through.
+		return 0, true, r.adoptCanary(ctx, site)
+	case webv1beta1.CanaryAborted:
+		return 0, false, nil
+	}
+	if len(cs.Steps) == 0 {
+		return 0, false, r.abortCanary(ctx, site, "spec.canary has no steps")
+	}
+
+	canary := canarySite(site)
+	deploy, err := r.reconcileCanaryStack(ctx, site, canary, status.Weight)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Status == corev1.ConditionFalse {
+			return 0, false, r.abortCanary(ctx, site, "Canary pods did not become ready: "+cond.Message)
+		}
+	}
+	if !rolledOut(deploy) {
+		if status.Weight > 0 {
+			return 0, false, r.abortCanary(ctx, site, "Canary pods became unavailable")
+		}
+		status.Message = "Waiting for " + deploy.Name + " to roll out"
+		return 0, false, nil
+	}
+
+	if limit := cs.MaxErrorPercentage; limit != nil && status.Weight > 0 {
+		percentage, ok, err := r.canaryErrorPercentage(ctx, canary)
+		if err != nil {
+			// Without metrics the rollout goes on, as it does without a limit.
+			log.FromContext(ctx).V(1).Info("failed to read canary error rate", "error", err.Error())
+		} else if ok && percentage > float64(*limit) {
+			return 0, false, r.abortCanary(ctx, site, fmt.Sprintf(
+				"%.1f%% of the canary requests failed, above the %d%% limit", percentage, *limit))
+		}
+	}
+
+	if status.StepStartTime != nil {
+		remaining := time.Until(status.StepStartTime.Add(canaryPause(cs.Steps[status.Step])))
+		if remaining > 0 {
+			return remaining, false, nil
+		}
+		if int(status.Step)+1 == len(cs.Steps) {
+			return 0, true, r.promoteCanary(ctx, site)
+		}
+		status.Step++
+	}
+	step := cs.Steps[status.Step]
+	status.Weight = step.Weight
+	status.StepStartTime = ptr.To(metav1.Now())
+	status.Message = fmt.Sprintf("Step %d of %d: sending %d%% of the requests to %s",
+		status.Step+1, len(cs.Steps), step.Weight, deploy.Name)
+	r.event(site, corev1.EventTypeNormal, eventCanaryStep, status.Message)
+	if err := r.applyCanaryRouting(ctx, site, canary, status.Weight); err != nil {
+		return 0, false, err
+	}
+	return canaryPause(step), false, nil
+}
+
+// reconcileCanaryStack applies the canary config, Deployment, Service and
+// Ingress, and returns the canary Deployment.
+func (r *NginxStaticSiteReconciler) reconcileCanaryStack(ctx context.Context, site, canary *webv1beta1.NginxStaticSite,
+	weight int32) (*appsv1.Deployment, error) {
+	config, err := r.reconcileConfig(ctx, canary)
+	if err != nil {
+		return nil, err
+	}
+	contentChecksum, err := r.reconcileProjectedContent(ctx, canary)
+	if err != nil {
+		return nil, err
+	}
+	deploy := desiredDeployment(canary, configChecksum(config), contentChecksum)
+	if usesPVC(site) {
+		// Keep the canary next to the live pods, which hold the claim.
+		if deploy.Spec.Template.Spec.Affinity, err = r.contentJobAffinity(ctx, site); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.apply(ctx, canary, deploy); err != nil {
+		return nil, err
+	}
+	if err := r.apply(ctx, canary, desiredService(canary)); err != nil {
+		return nil, err
+	}
+	return deploy, r.applyCanaryRouting(ctx, site, canary, weight)
+}
+
+// applyCanaryRouting sends weight percent of the requests to the canary,
+// through the canary Ingress or the backend weights of the site HTTPRoute.
+func (r *NginxStaticSiteReconciler) applyCanaryRouting(ctx context.Context, site, canary *webv1beta1.NginxStaticSite,
+	weight int32) error {
+	if gatewayRouting(site) {
+		return r.apply(ctx, site, desiredHTTPRoute(site, weight))
+	}
+	return r.apply(ctx, canary, desiredCanaryIngress(canary, weight))
+}
+
+// desiredCanaryIngress builds the canary Ingress, matching the site host and
+// path and sending weight percent of the requests to the canary Service.
+func desiredCanaryIngress(canary *webv1beta1.NginxStaticSite, weight int32) *networkingv1.Ingress {
+	ing := desiredIngress(canary)
+	ing.Annotations = map[string]string{
+		ingressCanaryAnnotation:       "true",
+		ingressCanaryWeightAnnotation: strconv.Itoa(int(weight)),
+	}
+	return ing
+}
+
+// canaryErrorPercentage returns the percentage of the requests to the canary
+// pods answered with a 5xx status, reporting false when there were none or
+// no custom metrics client is set.
+func (r *NginxStaticSiteReconciler) canaryErrorPercentage(ctx context.Context, canary *webv1beta1.NginxStaticSite) (float64, bool, error) {
+	if r.CustomMetrics == nil {
+		return 0, false, nil
+	}
+	requests, err := r.podsMetric(ctx, canary, requestsPerSecondMetric)
+	if err != nil || requests <= 0 {
+		return 0, false, err
+	}
+	errors, err := r.podsMetric(ctx, canary, serverErrorsPerSecondMetric)
+	if err != nil {
+		return 0, false, err
+	}
+	return 100 * errors / requests, true, nil
+}
+
+// podsMetric sums a pods metric of the custom metrics API over the nginx pods of site.
+func (r *NginxStaticSiteReconciler) podsMetric(ctx context.Context, site *webv1beta1.NginxStaticSite, metric string) (float64, error) {
+	raw, err := r.CustomMetrics.Get().
+		AbsPath("/apis/custom.metrics.k8s.io/v1beta1/namespaces", site.Namespace, "pods", "*", metric).
+		Param("labelSelector", labels.SelectorFromSet(selectorLabels(site)).String()).
+		DoRaw(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var list struct {
+		Items []struct {
+			Value resource.Quantity `json:"value"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return 0, fmt.Errorf("parsing metric %s: %w", metric, err)
+	}
+	sum := 0.0
+	for _, item := range list.Items {
+		sum += item.Value.AsApproximateFloat64()
+	}
+	return sum, nil
+}
+
+// abortCanary retires the canary stack, sending all requests back to the
+// live pods, and leaves spec.canary to be changed or removed.
+func (r *NginxStaticSiteReconciler) abortCanary(ctx context.Context, site *webv1beta1.NginxStaticSite, message string) error {
+	if err := r.retireCanary(ctx, site); err != nil {
+		return err
+	}
+	status := site.Status.Canary
+	status.Phase = webv1beta1.CanaryAborted
+	status.Weight = 0
+	status.Message = message
+	r.event(site, corev1.EventTypeWarning, eventCanaryAborted, message)
+	return nil
+}
+
+// promoteCanary retires the canary stack once every step passed and makes
+// the canary the live spec.
+func (r *NginxStaticSiteReconciler) promoteCanary(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if err := r.retireCanary(ctx, site); err != nil {
+		return err
+	}
+	status := site.Status.Canary
+	status.Phase = webv1beta1.CanaryPromoted
+	status.Weight = 0
+	status.Message = "Every step passed, rolling the canary out to " + deploymentName(site)
+	if err := r.Status().Update(ctx, site); err != nil {
+		return err
+	}
+	r.event(site, corev1.EventTypeNormal, eventCanaryPromoted, status.Message)
+	return r.adoptCanary(ctx, site)
+}
+
+// adoptCanary moves spec.canary into the live spec. A canary revision is
+// pinned with spec.content.revision.
+func (r *NginxStaticSiteReconciler) adoptCanary(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	cs := site.Spec.Canary
+	if cs.ImageVersion != "" {
+		site.Spec.Pod.ImageVersion = cs.ImageVersion
+	}
+	if cs.Revision != "" {
+		site.Spec.Content.Revision = cs.Revision
+	}
+	site.Spec.Canary = nil
+	return r.Update(ctx, site)
+}
+
+// retireCanary deletes the canary stack of the site.
+func (r *NginxStaticSiteReconciler) retireCanary(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if gatewayRouting(site) {
+		// Take the canary Service out of the route before deleting it.
+		if err := r.apply(ctx, site, desiredHTTPRoute(site, 0)); err != nil {
+			return err
+		}
+	}
+	canary := stackSite(site, canarySuffix)
+	objs := []client.Object{
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: ingressName(canary)}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName(canary)}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName(canary)}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMapName(canary)}},
+	}
+	for _, obj := range objs {
+		obj.SetNamespace(site.Namespace)
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// newFakeReconciler returns a reconciler backed by a fake client holding objs.
+// The fake client has no server-side apply, so an apply creates the object or
+// replaces its spec, keeping the status as the API server would.
+func newFakeReconciler(t *testing.T, objs ...client.Object) *NginxStaticSiteReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := webv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&webv1beta1.NginxStaticSite{}, &appsv1.Deployment{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if patch.Type() != types.ApplyPatchType {
+					return c.Patch(ctx, obj, patch, opts...)
+				}
+				current := obj.DeepCopyObject().(client.Object)
+				err := c.Get(ctx, client.ObjectKeyFromObject(obj), current)
+				if errors.IsNotFound(err) {
+					return c.Create(ctx, obj)
+				}
+				if err != nil {
+					return err
+				}
+				obj.SetResourceVersion(current.GetResourceVersion())
+				return c.Update(ctx, obj)
+			},
+		}).
+		Build()
+	return &NginxStaticSiteReconciler{Client: c, Scheme: scheme}
+}
+
+// fakeMetrics serves the given pods metric values from a fake custom metrics API.
+func fakeMetrics(values map[string]string) rest.Interface {
+	return &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			value, ok := values[path.Base(req.URL.Path)]
+			if !ok {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			body := fmt.Sprintf(`{"items":[{"value":%q}]}`, value)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}
+}
+
+func TestCanaryHash(t *testing.T) {
+	base := func() *webv1beta1.CanarySpec {
+		return &webv1beta1.CanarySpec{
+			ImageVersion: "1.27.1",
+			Steps: []webv1beta1.CanaryStep{
+				{Weight: 10, Pause: &metav1.Duration{Duration: time.Minute}},
+				{Weight: 50},
+			},
+		}
+	}
+	hash := canaryHash(base())
+	if len(hash) != 16 {
+		t.Fatalf("canaryHash() = %q, want 16 characters", hash)
+	}
+	if again := canaryHash(base()); again != hash {
+		t.Errorf("canaryHash() = %q then %q, want a stable hash", hash, again)
+	}
+
+	changes := map[string]func(*webv1beta1.CanarySpec){
+		"image version": func(cs *webv1beta1.CanarySpec) { cs.ImageVersion = "1.27.2" },
+		"revision":      func(cs *webv1beta1.CanarySpec) { cs.Revision = "v2" },
+		"replicas":      func(cs *webv1beta1.CanarySpec) { cs.Replicas = ptr.To[int32](2) },
+		"step weight":   func(cs *webv1beta1.CanarySpec) { cs.Steps[1].Weight = 60 },
+		"step pause":    func(cs *webv1beta1.CanarySpec) { cs.Steps[0].Pause = nil },
+		"error limit":   func(cs *webv1beta1.CanarySpec) { cs.MaxErrorPercentage = ptr.To[int32](5) },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			cs := base()
+			change(cs)
+			if canaryHash(cs) == hash {
+				t.Errorf("canaryHash() unchanged after changing the %s", name)
+			}
+		})
+	}
+}
+
+func TestReconcileCanary(t *testing.T) {
+	rolledOut := appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	tests := []struct {
+		name         string
+		canary       func(*webv1beta1.CanarySpec)
+		status       *webv1beta1.CanaryStatus
+		deployStatus *appsv1.DeploymentStatus
+		metrics      map[string]string
+		wantPhase    string
+		wantStep     int32
+		wantWeight   int32
+		wantRequeue  bool
+		wantPromoted bool
+	}{
+		{
+			name:       "waits for the canary pods",
+			wantPhase:  webv1beta1.CanaryProgressing,
+			wantWeight: 0,
+		},
+		{
+			name:         "starts the first step once rolled out",
+			deployStatus: &rolledOut,
+			wantPhase:    webv1beta1.CanaryProgressing,
+			wantWeight:   10,
+			wantRequeue:  true,
+		},
+		{
+			name:         "stays on a step during its pause",
+			status:       &webv1beta1.CanaryStatus{Weight: 10, StepStartTime: ptr.To(metav1.Now())},
+			deployStatus: &rolledOut,
+			wantPhase:    webv1beta1.CanaryProgressing,
+			wantWeight:   10,
+			wantRequeue:  true,
+		},
+		{
+			name:         "advances once the pause expired",
+			status:       &webv1beta1.CanaryStatus{Weight: 10, StepStartTime: ptr.To(metav1.NewTime(time.Now().Add(-2 * time.Minute)))},
+			deployStatus: &rolledOut,
+			wantPhase:    webv1beta1.CanaryProgressing,
+			wantStep:     1,
+			wantWeight:   50,
+			wantRequeue:  true,
+		},
+		{
+			name: "promotes after the last step",
+			status: &webv1beta1.CanaryStatus{Step: 1, Weight: 50,
+				StepStartTime: ptr.To(metav1.NewTime(time.Now().Add(-2 * time.Minute)))},
+			deployStatus: &rolledOut,
+			wantPhase:    webv1beta1.CanaryPromoted,
+			wantStep:     1,
+			wantPromoted: true,
+		},
+		{
+			name:         "adopts a promotion whose spec update did not go through",
+			status:       &webv1beta1.CanaryStatus{Phase: webv1beta1.CanaryPromoted, Step: 1},
+			wantPhase:    webv1beta1.CanaryPromoted,
+			wantStep:     1,
+			wantPromoted: true,
+		},
+		{
+			name: "aborts when the rollout stopped progressing",
+			deployStatus: &appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Message: "deadline exceeded"},
+			}},
+			wantPhase: webv1beta1.CanaryAborted,
+		},
+		{
+			name:         "aborts when pods become unavailable while serving",
+			status:       &webv1beta1.CanaryStatus{Weight: 10, StepStartTime: ptr.To(metav1.Now())},
+			deployStatus: &appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1},
+			wantPhase:    webv1beta1.CanaryAborted,
+		},
+		{
+			name:         "aborts above the error limit",
+			canary:       func(cs *webv1beta1.CanarySpec) { cs.MaxErrorPercentage = ptr.To[int32](5) },
+			status:       &webv1beta1.CanaryStatus{Weight: 10, StepStartTime: ptr.To(metav1.Now())},
+			deployStatus: &rolledOut,
+			metrics:      map[string]string{requestsPerSecondMetric: "10", serverErrorsPerSecondMetric: "1"},
+			wantPhase:    webv1beta1.CanaryAborted,
+		},
+		{
+			name:         "goes on below the error limit",
+			canary:       func(cs *webv1beta1.CanarySpec) { cs.MaxErrorPercentage = ptr.To[int32](5) },
+			status:       &webv1beta1.CanaryStatus{Weight: 10, StepStartTime: ptr.To(metav1.Now())},
+			deployStatus: &rolledOut,
+			metrics:      map[string]string{requestsPerSecondMetric: "10", serverErrorsPerSecondMetric: "100m"},
+			wantPhase:    webv1beta1.CanaryProgressing,
+			wantWeight:   10,
+			wantRequeue:  true,
+		},
+		{
+			name:      "aborts without steps",
+			canary:    func(cs *webv1beta1.CanarySpec) { cs.Steps = nil },
+			wantPhase: webv1beta1.CanaryAborted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			site := testSite()
+			site.Spec.Canary = &webv1beta1.CanarySpec{
+				ImageVersion: "1.27.1",
+				Steps: []webv1beta1.CanaryStep{
+					{Weight: 10, Pause: &metav1.Duration{Duration: time.Minute}},
+					{Weight: 50, Pause: &metav1.Duration{Duration: time.Minute}},
+				},
+			}
+			if tt.canary != nil {
+				tt.canary(site.Spec.Canary)
+			}
+			if tt.status != nil {
+				site.Status.Canary = tt.status.DeepCopy()
+				site.Status.Canary.SpecHash = canaryHash(site.Spec.Canary)
+				if site.Status.Canary.Phase == "" {
+					site.Status.Canary.Phase = webv1beta1.CanaryProgressing
+				}
+			}
+			objs := []client.Object{site.DeepCopy()}
+			if tt.deployStatus != nil {
+				deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Name: deploymentName(stackSite(site, canarySuffix)), Namespace: site.Namespace, Generation: 1}}
+				deploy.Status = *tt.deployStatus
+				objs = append(objs, deploy)
+			}
+			r := newFakeReconciler(t, objs...)
+			if tt.metrics != nil {
+				r.CustomMetrics = fakeMetrics(tt.metrics)
+			}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(site), site); err != nil {
+				t.Fatal(err)
+			}
+
+			requeue, promoted, err := r.reconcileCanary(ctx, site)
+			if err != nil {
+				t.Fatalf("reconcileCanary() error = %v", err)
+			}
+			status := site.Status.Canary
+			if status.Phase != tt.wantPhase || status.Step != tt.wantStep || status.Weight != tt.wantWeight {
+				t.Errorf("status = %s step %d weight %d (%s), want %s step %d weight %d", status.Phase, status.Step,
+					status.Weight, status.Message, tt.wantPhase, tt.wantStep, tt.wantWeight)
+			}
+			if (requeue > 0) != tt.wantRequeue {
+				t.Errorf("requeue = %v, want requeue %v", requeue, tt.wantRequeue)
+			}
+			if promoted != tt.wantPromoted {
+				t.Errorf("promoted = %v, want %v", promoted, tt.wantPromoted)
+			}
+
+			stored := &webv1beta1.NginxStaticSite{}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(site), stored); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantPromoted && (stored.Spec.Canary != nil || stored.Spec.Pod.ImageVersion != "1.27.1") {
+				t.Errorf("promoted spec = canary %+v, image %s, want the canary image and no canary",
+					stored.Spec.Canary, stored.Spec.Pod.ImageVersion)
+			}
+			canary := &appsv1.Deployment{}
+			err = r.Get(ctx, client.ObjectKey{Name: deploymentName(stackSite(site, canarySuffix)), Namespace: site.Namespace}, canary)
+			if retired := errors.IsNotFound(err); retired != (tt.wantPhase != webv1beta1.CanaryProgressing) {
+				t.Errorf("canary Deployment retired = %v (%v), want %v", retired, err, !retired)
+			}
+		})
+	}
+}
+
+func TestCanaryErrorPercentage(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics map[string]string
+		want    float64
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name: "no custom metrics client",
+		},
+		{
+			name:    "no requests",
+			metrics: map[string]string{requestsPerSecondMetric: "0", serverErrorsPerSecondMetric: "0"},
+		},
+		{
+			name:    "share of 5xx responses",
+			metrics: map[string]string{requestsPerSecondMetric: "20", serverErrorsPerSecondMetric: "500m"},
+			want:    2.5,
+			wantOK:  true,
+		},
+		{
+			name:    "metric not served",
+			metrics: map[string]string{requestsPerSecondMetric: "20"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &NginxStaticSiteReconciler{}
+			if tt.metrics != nil {
+				r.CustomMetrics = fakeMetrics(tt.metrics)
+			}
+			got, ok, err := r.canaryErrorPercentage(context.Background(), stackSite(testSite(), canarySuffix))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("canaryErrorPercentage() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("canaryErrorPercentage() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCanaryRouting(t *testing.T) {
+	site := testSite()
+	site.Spec.Routing.Host = "docs.example.com"
+	site.Spec.Canary = &webv1beta1.CanarySpec{Steps: []webv1beta1.CanaryStep{{Weight: 30}}}
+
+	ing := desiredCanaryIngress(canarySite(site), 30)
+	if ing.Annotations[ingressCanaryAnnotation] != "true" || ing.Annotations[ingressCanaryWeightAnnotation] != "30" {
+		t.Errorf("canary Ingress annotations = %v, want canary weight 30", ing.Annotations)
+	}
+	if rules := ing.Spec.Rules; len(rules) != 1 || rules[0].Host != "docs.example.com" {
+		t.Errorf("canary Ingress rules = %+v, want the site host", rules)
+	}
+
+	site.Spec.Routing.Type = webv1beta1.RoutingTypeGatewayAPI
+	site.Spec.Routing.Gateway = &webv1beta1.GatewayReference{Name: "web"}
+	for _, weight := range []int32{0, 30} {
+		route := desiredHTTPRoute(site, weight)
+		rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+		refs, _, _ := unstructured.NestedSlice(rules[0].(map[string]any), "backendRefs")
+		want := []any{backendRef(serviceName(site), 100-weight)}
+		if weight > 0 {
+			want = append(want, backendRef(serviceName(stackSite(site, canarySuffix)), weight))
+		}
+		if fmt.Sprint(refs) != fmt.Sprint(want) {
+			t.Errorf("backendRefs at weight %d = %v, want %v", weight, refs, want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+const (
+	// configFileName is the key of the rendered server block in the site ConfigMap.
+	configFileName = "default.conf"
+	// configMountPath replaces the stock conf.d of the nginx image.
+	configMountPath = "/etc/nginx/conf.d"
+	// configChecksumAnnotation on the pod template rolls the pods when the
+	// rendered config changes.
+	configChecksumAnnotation = "web.ictplus.ir/config-checksum"
+	// defaultSnippetKey is read from the snippet ConfigMap when no key is given.
+	defaultSnippetKey = "snippet.conf"
+	// configTestSiteLabel marks the nginx -t Jobs of a site.
+	configTestSiteLabel = "web.ictplus.ir/config-test-of"
+)
+
+var serverTemplate = template.Must(template.New(configFileName).Funcs(template.FuncMap{
+	"join": func(s []string) string { return strings.Join(s, " ") },
+	"indent": func(s string) string {
+		return "    " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n    ")
+	},
+}).Parse(`server {
+    listen       80;
+    listen  [::]:80;
+    server_name  _;
+
+    root   {{ .Root }};
+    index  {{ join .Index }};
+{{- if .Gzip }}
+
+    gzip on;
+    gzip_types {{ if .Gzip.Types }}{{ join .Gzip.Types }}{{ else }}text/plain{{ end }};
+{{- if .Gzip.MinLength }}
+    gzip_min_length {{ .Gzip.MinLength }};
+{{- end }}
+{{- end }}
+{{- if .Expires }}
+
+    expires {{ .Expires }};
+{{- end }}
+{{- range .Headers }}
+    add_header {{ .Name }} {{ .Value }} always;
+{{- end }}
+
+    location / {
+        try_files {{ join .TryFiles }};
+    }
+{{- if .Snippet }}
+
+{{ indent .Snippet }}
+{{- end }}
+}
+{{- if .SyncStatus }}
+
+server {
+    listen {{ .SyncStatusPort }};
+
+    location = /{{ .SyncStatusFile }} {
+        default_type text/plain;
+        alias {{ .SyncStatus }};
+    }
+}
+{{- end }}
+`))
+
+// header is a response header with its value quoted for nginx.
+type header struct {
+	Name, Value string
+}
+
+// serverConfig holds the spec.server values after defaulting.
+type serverConfig struct {
+	Root     string
+	Index    []string
+	TryFiles []string
+	Gzip     *webv1beta1.GzipSpec
+	Expires  string
+	Headers  []header
+	Snippet  string
+
+	// SyncStatus is the git-sync status file served on the internal port.
+	SyncStatus     string
+	SyncStatusPort int
+	SyncStatusFile string
+}
+
+// renderServerConfig renders the nginx server block for the site, ending with
+// the given snippet. The output is deterministic so its checksum only changes
+// with the inputs.
+func renderServerConfig(site *webv1beta1.NginxStaticSite, snippet string) (string, error) {
+	server := site.Spec.Server
+	cfg := serverConfig{
+		Root:     server.Root,
+		Index:    server.Index,
+		TryFiles: server.TryFiles,
+		Gzip:     server.Gzip,
+		Expires:  server.Expires,
+		Snippet:  snippet,
+	}
+	if cfg.Root == "" {
+		cfg.Root = contentRoot(site)
+	}
+	if gitSource(site) != nil {
+		cfg.SyncStatus = path.Join(site.Spec.Content.Path, syncStatusFile)
+		cfg.SyncStatusPort = syncStatusPort
+		cfg.SyncStatusFile = syncStatusFile
+	}
+	if len(cfg.Index) == 0 {
+		cfg.Index = []string{"index.html", "index.htm"}
+	}
+	if len(cfg.TryFiles) == 0 {
+		cfg.TryFiles = []string{"$uri", "$uri/", "=404"}
+	}
+	for name, value := range server.Headers {
+		cfg.Headers = append(cfg.Headers, header{Name: name, Value: quote(value)})
+	}
+	sort.Slice(cfg.Headers, func(i, j int) bool { return cfg.Headers[i].Name < cfg.Headers[j].Name })
+
+	var buf bytes.Buffer
+	if err := serverTemplate.Execute(&buf, cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// quote makes s a single nginx string argument.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// desiredConfigMap builds the ConfigMap holding the rendered nginx config and,
+// for git sources, the git-sync exechook.
+func desiredConfigMap(site *webv1beta1.NginxStaticSite, config string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName(site),
+			Namespace: site.Namespace,
+		},
+		Data: map[string]string{
+			configFileName: config,
+		},
+	}
+	if gitSource(site) != nil {
+		cm.Data[syncStatusScript] = syncStatusHook
+	}
+	return cm
+}
+
+// configChecksum returns the hex sha256 of the rendered config.
+func configChecksum(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// configTestChecksum returns the hex sha256 of config and the image it is
+// tested with, so a new image is tested like a new config.
+func configTestChecksum(site *webv1beta1.NginxStaticSite, config string) string {
+	return configChecksum("nginx:" + site.Spec.Pod.ImageVersion + "\n" + config)
+}
+
+// reconcileConfig applies the nginx config of the site and returns it. A
+// config with a server snippet is only rolled out once an nginx -t Job has
+// accepted it with the site image; until then the config already running is
+// kept. The Job of the current candidate is kept as the record of its result,
+// so a failed one is not run again until the config or image changes.
+func (r *NginxStaticSiteReconciler) reconcileConfig(ctx context.Context, site *webv1beta1.NginxStaticSite) (string, error) {
+	ref := site.Spec.Server.SnippetRef
+	snippet := ""
+	if ref != nil {
+		key := ref.Key
+		if key == "" {
+			key = defaultSnippetKey
+		}
+		var cm corev1.ConfigMap
+		err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: site.Namespace}, &cm)
+		if err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		var ok bool
+		if snippet, ok = cm.Data[key]; !ok {
+			setCondition(site, webv1beta1.ConditionConfigInvalid, metav1.ConditionTrue, webv1beta1.ReasonSnippetNotFound,
+				fmt.Sprintf("Key %q of ConfigMap %s not found", key, ref.Name))
+			return r.keepConfig(ctx, site)
+		}
+	}
+
+	config, err := renderServerConfig(site, snippet)
+	if err != nil {
+		return "", err
+	}
+	keep := ""
+	if ref != nil {
+		keep = desiredConfigTestJob(site, config).Name
+	}
+	if err := r.deleteSiteJobs(ctx, site, configTestSiteLabel, keep); err != nil {
+		return "", err
+	}
+	if ref != nil && configTestChecksum(site, config) != site.Status.TestedConfigChecksum {
+		passed, err := r.testConfig(ctx, site, config)
+		if err != nil {
+			return "", err
+		}
+		if !passed {
+			return r.keepConfig(ctx, site)
+		}
+		site.Status.TestedConfigChecksum = configTestChecksum(site, config)
+	}
+
+	setCondition(site, webv1beta1.ConditionConfigInvalid, metav1.ConditionFalse, webv1beta1.ReasonAsExpected,
+		"Config is rolled out")
+	return config, r.applyConfig(ctx, site, config)
+}
+
+// testConfig runs config through nginx -t against the site image and reports
+// whether it passed, setting ConfigInvalid while the Job runs or after it failed.
+func (r *NginxStaticSiteReconciler) testConfig(ctx context.Context, site *webv1beta1.NginxStaticSite, config string) (bool, error) {
+	job := desiredConfigTestJob(site, config)
+	if err := r.apply(ctx, site, job); err != nil {
+		return false, err
+	}
+	if finishedAt, failed := jobFinished(job); finishedAt != nil {
+		if failed {
+			setCondition(site, webv1beta1.ConditionConfigInvalid, metav1.ConditionTrue, webv1beta1.ReasonNginxTestFailed,
+				r.jobOutput(ctx, job, "nginx -t failed"))
+		}
+		return !failed, nil
+	}
+	setCondition(site, webv1beta1.ConditionConfigInvalid, metav1.ConditionUnknown, webv1beta1.ReasonConfigValidating,
+		"Waiting for Job "+job.Name+" to run nginx -t")
+	return false, nil
+}
+
+// jobOutput returns the termination message of the Job's pod, which holds the
+// tail of its log, or fallback when there is none.
+func (r *NginxStaticSiteReconciler) jobOutput(ctx context.Context, job *batchv1.Job, fallback string) string {
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return fallback
+	}
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil && status.State.Terminated.Message != "" {
+				return strings.TrimSpace(status.State.Terminated.Message)
+			}
+		}
+	}
+	return fallback
+}
+
+// keepConfig re-applies the config currently rolled out. A new site, or one
+// whose running config was edited behind the operator's back, falls back to
+// the config without the snippet.
+func (r *NginxStaticSiteReconciler) keepConfig(ctx context.Context, site *webv1beta1.NginxStaticSite) (string, error) {
+	var cm corev1.ConfigMap
+	err := r.Get(ctx, client.ObjectKey{Name: configMapName(site), Namespace: site.Namespace}, &cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	config, ok := cm.Data[configFileName]
+	if !ok || configChecksum(config) != site.Status.ConfigChecksum {
+		if config, err = renderServerConfig(site, ""); err != nil {
+			return "", err
+		}
+	}
+	return config, r.applyConfig(ctx, site, config)
+}
+
+// sitesForConfigMap maps a ConfigMap to the sites using it as server snippet
+// or content, so edits to it are validated and rolled out without a spec change.
+func (r *NginxStaticSiteReconciler) sitesForConfigMap(ctx context.Context, obj client.Object) []reconcile.Request {
+	var sites webv1beta1.NginxStaticSiteList
+	if err := r.List(ctx, &sites, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, site := range sites.Items {
+		snippet := site.Spec.Server.SnippetRef
+		content := configMapSource(&site)
+		var release *corev1.LocalObjectReference
+		if site.Spec.Release != nil {
+			release = configMapSource(releaseSite(&site))
+		}
+		if (snippet != nil && snippet.Name == obj.GetName()) || (content != nil && content.Name == obj.GetName()) ||
+			(release != nil && release.Name == obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&site)})
+		}
+	}
+	return requests
+}
+
+// sitesForSecret maps a Secret to the sites using it as content, so edits to
+// it are rolled out without a spec change.
+func (r *NginxStaticSiteReconciler) sitesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var sites webv1beta1.NginxStaticSiteList
+	if err := r.List(ctx, &sites, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, site := range sites.Items {
+		content := secretSource(&site)
+		var release *corev1.LocalObjectReference
+		if site.Spec.Release != nil {
+			release = secretSource(releaseSite(&site))
+		}
+		if (content != nil && content.Name == obj.GetName()) || (release != nil && release.Name == obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&site)})
+		}
+	}
+	return requests
+}
+
+// applyConfig applies the site ConfigMap and records config as rolled out.
+func (r *NginxStaticSiteReconciler) applyConfig(ctx context.Context, site *webv1beta1.NginxStaticSite, config string) error {
+	if err := r.apply(ctx, site, desiredConfigMap(site, config)); err != nil {
+		return err
+	}
+	site.Status.ConfigChecksum = configChecksum(config)
+	return nil
+}
+
+// desiredConfigTestJob builds the Job running nginx -t on config with the site
+// image. It is named after the checksum of both, so every candidate is tested once.
+func desiredConfigTestJob(site *webv1beta1.NginxStaticSite, config string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stackName(site) + "-config-test-" + configTestChecksum(site, config)[:10],
+			Namespace: site.Namespace,
+			Labels:    map[string]string{configTestSiteLabel: stackName(site)},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](0),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "nginx-test",
+							Image:   "nginx:" + site.Spec.Pod.ImageVersion,
+							Command: []string{"sh", "-c", `printf '%s' "$NGINX_CONFIG" > ` + configMountPath + "/" + configFileName + " && nginx -t"},
+							Env: []corev1.EnvVar{
+								{Name: "NGINX_CONFIG", Value: config},
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func testSite() *webv1beta1.NginxStaticSite {
+	site := &webv1beta1.NginxStaticSite{}
+	site.Name = "docs"
+	site.Namespace = "default"
+	site.Spec.Storage.Size = "1Gi"
+	site.Spec.Content.Path = "/usr/share/nginx/html"
+	site.Spec.Pod.ImageVersion = "1.27.0"
+	return site
+}
+
+func TestRenderServerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  webv1beta1.ServerSpec
+		source  *webv1beta1.SourceSpec
+		snippet string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				"    root   /usr/share/nginx/html;\n",
+				"    index  index.html index.htm;\n",
+				"        try_files $uri $uri/ =404;\n",
+			},
+			notWant: []string{"gzip", "expires", "add_header", "listen 8081"},
+		},
+		{
+			name:   "gzip with default types",
+			server: webv1beta1.ServerSpec{Gzip: &webv1beta1.GzipSpec{}},
+			want:   []string{"    gzip on;\n    gzip_types text/plain;\n"},
+			notWant: []string{
+				"gzip_min_length",
+			},
+		},
+		{
+			name: "gzip with types and minimum length",
+			server: webv1beta1.ServerSpec{Gzip: &webv1beta1.GzipSpec{
+				Types:     []string{"text/css", "application/javascript"},
+				MinLength: ptr.To[int32](256),
+			}},
+			want: []string{
+				"    gzip_types text/css application/javascript;\n",
+				"    gzip_min_length 256;\n",
+			},
+		},
+		{
+			name:   "expires",
+			server: webv1beta1.ServerSpec{Expires: "7d"},
+			want:   []string{"    expires 7d;\n"},
+		},
+		{
+			name: "headers sorted and quoted",
+			server: webv1beta1.ServerSpec{Headers: map[string]string{
+				"X-Frame-Options":         "DENY",
+				"Content-Security-Policy": `default-src 'self'; script-src "x" \y`,
+			}},
+			want: []string{
+				"    add_header Content-Security-Policy \"default-src 'self'; script-src \\\"x\\\" \\\\y\" always;\n" +
+					"    add_header X-Frame-Options \"DENY\" always;\n",
+			},
+		},
+		{
+			name:   "custom root, index and try_files",
+			server: webv1beta1.ServerSpec{Root: "/srv/www", Index: []string{"main.html"}, TryFiles: []string{"$uri", "/index.html"}},
+			want: []string{
+				"    root   /srv/www;\n",
+				"    index  main.html;\n",
+				"        try_files $uri /index.html;\n",
+			},
+		},
+		{
+			name:    "snippet indented into the server block",
+			snippet: "location /api {\n    return 404;\n}\n",
+			want:    []string{"\n    location /api {\n        return 404;\n    }\n}\n"},
+		},
+		{
+			name:   "git status server",
+			source: &webv1beta1.SourceSpec{Git: &webv1beta1.GitSource{URL: "https://example.com/site.git"}},
+			want: []string{
+				"    root   /usr/share/nginx/html/current;\n",
+				"server {\n    listen 8081;\n\n    location = /.sync-status {\n" +
+					"        default_type text/plain;\n        alias /usr/share/nginx/html/.sync-status;\n    }\n}\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Server = tt.server
+			site.Spec.Source = tt.source
+			config, err := renderServerConfig(site, tt.snippet)
+			if err != nil {
+				t.Fatalf("renderServerConfig() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(config, want) {
+					t.Errorf("config does not contain %q:\n%s", want, config)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(config, notWant) {
+					t.Errorf("config contains %q:\n%s", notWant, config)
+				}
+			}
+			again, _ := renderServerConfig(site, tt.snippet)
+			if again != config {
+				t.Errorf("renderServerConfig() is not deterministic")
+			}
+		})
+	}
+}
+
+func TestDesiredConfigTestJob(t *testing.T) {
+	site := testSite()
+	job := desiredConfigTestJob(site, "server {}\n")
+	if job.Spec.TTLSecondsAfterFinished != nil {
+		t.Errorf("TTLSecondsAfterFinished = %d, want unset", *job.Spec.TTLSecondsAfterFinished)
+	}
+	if got := job.Labels[configTestSiteLabel]; got != "docs" {
+		t.Errorf("label %s = %q, want %q", configTestSiteLabel, got, "docs")
+	}
+	if again := desiredConfigTestJob(site, "server {}\n"); again.Name != job.Name {
+		t.Errorf("name changed from %s to %s for the same config and image", job.Name, again.Name)
+	}
+	if other := desiredConfigTestJob(site, "server { }\n"); other.Name == job.Name {
+		t.Errorf("name %s not changed by the config", job.Name)
+	}
+	site.Spec.Pod.ImageVersion = "1.27.1"
+	if other := desiredConfigTestJob(site, "server {}\n"); other.Name == job.Name {
+		t.Errorf("name %s not changed by the image", job.Name)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// contentChecksumAnnotation on the pod template rolls the nginx pods whenever
+// the ConfigMap of a configMapRef source or the Secret of a secretRef source
+// changes.
+const contentChecksumAnnotation = "web.ictplus.ir/content-checksum"
+
+// configMapSource returns spec.source.configMapRef, or nil when the site is
+// not served from a ConfigMap.
+func configMapSource(site *webv1beta1.NginxStaticSite) *corev1.LocalObjectReference {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.ConfigMapRef
+}
+
+// secretSource returns spec.source.secretRef, or nil when the site is not
+// served from a Secret.
+func secretSource(site *webv1beta1.NginxStaticSite) *corev1.LocalObjectReference {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.SecretRef
+}
+
+// projected reports whether the site content is projected from a ConfigMap
+// or Secret.
+func projected(site *webv1beta1.NginxStaticSite) bool {
+	return configMapSource(site) != nil || secretSource(site) != nil
+}
+
+// reconcileProjectedContent reports ContentReady for a configMapRef or
+// secretRef source and returns the checksum of the projected files, or "" for
+// other sources and while the ConfigMap or Secret is missing.
+func (r *NginxStaticSiteReconciler) reconcileProjectedContent(ctx context.Context, site *webv1beta1.NginxStaticSite) (string, error) {
+	var kind, name string
+	files := map[string]string{}
+	if ref := configMapSource(site); ref != nil {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: site.Namespace}, &cm); err != nil {
+			return "", r.projectedSourceMissing(site, "ConfigMap", ref.Name, err)
+		}
+		kind, name = "ConfigMap", ref.Name
+		for key, value := range cm.Data {
+			files[key] = value
+		}
+		for key, value := range cm.BinaryData {
+			files[key] = string(value)
+		}
+	} else if ref := secretSource(site); ref != nil {
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: site.Namespace}, &secret); err != nil {
+			return "", r.projectedSourceMissing(site, "Secret", ref.Name, err)
+		}
+		kind, name = "Secret", ref.Name
+		for key, value := range secret.Data {
+			files[key] = string(value)
+		}
+	} else {
+		return "", nil
+	}
+	setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionTrue, webv1beta1.ReasonProjected,
+		fmt.Sprintf("Serving %d files from %s %s", len(files), kind, name))
+	return projectedContentChecksum(files), nil
+}
+
+// projectedSourceMissing sets ContentReady to False when the ConfigMap or
+// Secret of the source does not exist, and returns any other error.
+func (r *NginxStaticSiteReconciler) projectedSourceMissing(site *webv1beta1.NginxStaticSite, kind, name string, err error) error {
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonContentNotFound,
+		kind+" "+name+" not found")
+	return nil
+}
+
+// projectedContentChecksum hashes the projected files, in key order.
+func projectedContentChecksum(files map[string]string) string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s\n%d\n%s\n", key, len(files[key]), files[key])
+	}
+	return configChecksum(b.String())
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestReconcileProjectedContent(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Name: "maintenance", Namespace: "default"}
+	tests := []struct {
+		name      string
+		source    *webv1beta1.SourceSpec
+		objs      []client.Object
+		wantReady metav1.ConditionStatus
+		wantSum   bool
+	}{
+		{
+			name:   "configMap",
+			source: &webv1beta1.SourceSpec{ConfigMapRef: &corev1.LocalObjectReference{Name: "maintenance"}},
+			objs: []client.Object{&corev1.ConfigMap{ObjectMeta: objectMeta,
+				Data: map[string]string{"index.html": "down"}}},
+			wantReady: metav1.ConditionTrue,
+			wantSum:   true,
+		},
+		{
+			name:   "secret",
+			source: &webv1beta1.SourceSpec{SecretRef: &corev1.LocalObjectReference{Name: "maintenance"}},
+			objs: []client.Object{&corev1.Secret{ObjectMeta: objectMeta,
+				Data: map[string][]byte{"index.html": []byte("down")}}},
+			wantReady: metav1.ConditionTrue,
+			wantSum:   true,
+		},
+		{
+			name:      "missing secret",
+			source:    &webv1beta1.SourceSpec{SecretRef: &corev1.LocalObjectReference{Name: "maintenance"}},
+			wantReady: metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Source = tt.source
+			r := newFakeReconciler(t, tt.objs...)
+			sum, err := r.reconcileProjectedContent(context.Background(), site)
+			if err != nil {
+				t.Fatalf("reconcileProjectedContent() error = %v", err)
+			}
+			if (sum != "") != tt.wantSum {
+				t.Errorf("checksum = %q, want one %v", sum, tt.wantSum)
+			}
+			cond := meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionContentReady)
+			if cond == nil || cond.Status != tt.wantReady {
+				t.Errorf("ContentReady = %+v, want status %s", cond, tt.wantReady)
+			}
+			if usesPVC(site) {
+				t.Errorf("usesPVC() = true, want the content projected without a claim")
+			}
+		})
+	}
+}
+
+func TestProjectedContentChecksum(t *testing.T) {
+	sum := projectedContentChecksum(map[string]string{"index.html": "down", "style.css": "body{}"})
+	if again := projectedContentChecksum(map[string]string{"style.css": "body{}", "index.html": "down"}); again != sum {
+		t.Errorf("checksum changed with the key order: %s and %s", sum, again)
+	}
+	if edited := projectedContentChecksum(map[string]string{"index.html": "up", "style.css": "body{}"}); edited == sum {
+		t.Errorf("checksum %s unchanged after an edit", sum)
+	}
+	if moved := projectedContentChecksum(map[string]string{"index.htmldown": "", "style.css": "body{}"}); moved == sum {
+		t.Errorf("checksum %s unchanged after moving bytes between key and value", sum)
+	}
+}
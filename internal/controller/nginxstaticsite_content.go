@@ -0,0 +1,237 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"path"
+	"slices"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+const (
+	// contentJobMountPath is where Jobs filling the site volume mount it.
+	contentJobMountPath = "/data"
+	// currentLink is the symlink on the site volume pointing at the content
+	// being served, for sources that swap in new content as a whole.
+	currentLink = "current"
+)
+
+// reconcileContent runs the Jobs filling the site volume from an S3 or
+// archive source and switching its revisions, and reports ContentReady, which
+// a configMapRef or secretRef source reports before the Deployment is applied. It returns when the next periodic
+// sync is due, or zero when none is scheduled.
+func (r *NginxStaticSiteReconciler) reconcileContent(ctx context.Context, site *webv1beta1.NginxStaticSite) (time.Duration, error) {
+	requeueAfter, err := r.reconcileS3(ctx, site)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.reconcileArchive(ctx, site); err != nil {
+		return 0, err
+	}
+	if err := r.reconcileRevision(ctx, site); err != nil {
+		return 0, err
+	}
+	// In emptyDir mode the S3 and archive sources are fetched by init
+	// containers and the pod readiness covers them.
+	runsJobs := !fetchesAtStartup(site) && revisioned(site)
+	if !runsJobs && !projected(site) {
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionContentReady)
+	}
+	return requeueAfter, nil
+}
+
+// contentRoot is the directory nginx serves by default, following the
+// current symlink for sources that maintain one.
+func contentRoot(site *webv1beta1.NginxStaticSite) string {
+	switch {
+	case gitSource(site) != nil:
+		return path.Join(site.Spec.Content.Path, currentLink, site.Spec.Source.Git.Subdirectory)
+	case revisioned(site):
+		return path.Join(site.Spec.Content.Path, currentLink)
+	case imageSource(site) != nil && imageVolumeMount(site.Spec.Source.Image):
+		return path.Join(site.Spec.Content.Path, imagePath(site.Spec.Source.Image))
+	}
+	return site.Spec.Content.Path
+}
+
+// contentVolume is the site volume as mounted by the nginx pods and content
+// Jobs: the site PVC, the emptyDir of emptyDir mode, the ConfigMap or Secret
+// of a configMapRef or secretRef source, or for an image source the image
+// itself or the emptyDir it is copied into.
+func contentVolume(site *webv1beta1.NginxStaticSite) corev1.Volume {
+	if image := imageSource(site); image != nil {
+		source := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+		if imageVolumeMount(image) {
+			source = corev1.VolumeSource{Image: &corev1.ImageVolumeSource{
+				Reference:  pinnedImage(site),
+				PullPolicy: corev1.PullIfNotPresent,
+			}}
+		}
+		return corev1.Volume{Name: "static-content", VolumeSource: source}
+	}
+	if ref := configMapSource(site); ref != nil {
+		return corev1.Volume{
+			Name: "static-content",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: *ref},
+			},
+		}
+	}
+	if ref := secretSource(site); ref != nil {
+		return corev1.Volume{
+			Name: "static-content",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: ref.Name},
+			},
+		}
+	}
+	if fetchesAtStartup(site) {
+		emptyDir := &corev1.EmptyDirVolumeSource{}
+		if size, err := resource.ParseQuantity(site.Spec.Storage.Size); err == nil {
+			emptyDir.SizeLimit = &size
+		}
+		return corev1.Volume{Name: "static-content", VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir}}
+	}
+	return corev1.Volume{
+		Name: "static-content",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: contentClaimName(site),
+				ReadOnly:  storageMode(site) == webv1beta1.StorageModeReadOnlyMany,
+			},
+		},
+	}
+}
+
+// contentClaimName is the PVC holding the site content: spec.storage.existingClaim,
+// or the one the operator created, which moves on with every storage migration.
+func contentClaimName(site *webv1beta1.NginxStaticSite) string {
+	if claim := site.Spec.Storage.ExistingClaim; claim != "" {
+		return claim
+	}
+	if storage := site.Status.Storage; storage != nil && storage.ClaimName != "" {
+		return storage.ClaimName
+	}
+	return pvcName(site)
+}
+
+// storageMode returns spec.storage.mode, defaulting to a PVC.
+func storageMode(site *webv1beta1.NginxStaticSite) string {
+	if site.Spec.Storage.Mode == "" {
+		return webv1beta1.StorageModePVC
+	}
+	return site.Spec.Storage.Mode
+}
+
+// fetchesAtStartup reports whether every replica fetches the content into its
+// own emptyDir rather than sharing the site PVC.
+func fetchesAtStartup(site *webv1beta1.NginxStaticSite) bool {
+	return storageMode(site) == webv1beta1.StorageModeEmptyDir
+}
+
+// usesPVC reports whether the site content lives on the site PVC.
+func usesPVC(site *webv1beta1.NginxStaticSite) bool {
+	return imageSource(site) == nil && !projected(site) && !fetchesAtStartup(site)
+}
+
+// contentJobAffinity schedules a pod writing to the site volume next to the
+// nginx pods, as a ReadWriteOnce claim can only be mounted on one node. It
+// returns nil when the claim spans nodes or no nginx pod is scheduled, so the
+// pod does not wait for one that may never come, for instance with
+// spec.replicas 0.
+func (r *NginxStaticSiteReconciler) contentJobAffinity(ctx context.Context, site *webv1beta1.NginxStaticSite) (*corev1.Affinity, error) {
+	modes := pvcAccessModes(site)
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Name: contentClaimName(site), Namespace: site.Namespace}, pvc)
+	if err == nil {
+		modes = pvc.Spec.AccessModes
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if slices.ContainsFunc(modes, func(mode corev1.PersistentVolumeAccessMode) bool {
+		return mode == corev1.ReadWriteMany || mode == corev1.ReadOnlyMany
+	}) {
+		return nil, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(site.Namespace), client.MatchingLabels(selectorLabels(site))); err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(pods.Items, func(pod corev1.Pod) bool {
+		return pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil
+	}) {
+		return nil, nil
+	}
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: selectorLabels(site)},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			},
+		},
+	}, nil
+}
+
+// applyContentJob creates a Job writing to the site volume, scheduled by
+// contentJobAffinity, or reads back the one created before into job. The pod
+// template of a Job cannot change, so the affinity picked at creation stays.
+func (r *NginxStaticSiteReconciler) applyContentJob(ctx context.Context, site *webv1beta1.NginxStaticSite, job *batchv1.Job) error {
+	existing := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(job), existing)
+	if err == nil {
+		*job = *existing
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	if job.Spec.Template.Spec.Affinity, err = r.contentJobAffinity(ctx, site); err != nil {
+		return err
+	}
+	return r.apply(ctx, site, job)
+}
+
+// jobFinished returns when the Job completed or failed, or nil while it runs.
+func jobFinished(job *batchv1.Job) (*metav1.Time, bool) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return ptr.To(cond.LastTransitionTime), false
+		case batchv1.JobFailed:
+			return ptr.To(cond.LastTransitionTime), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestContentRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   *webv1beta1.SourceSpec
+		revision string
+		want     string
+	}{
+		{
+			name: "filled by hand",
+			want: "/usr/share/nginx/html",
+		},
+		{
+			name:     "uploaded revisions",
+			revision: "v1",
+			want:     "/usr/share/nginx/html/current",
+		},
+		{
+			name:   "git",
+			source: &webv1beta1.SourceSpec{Git: &webv1beta1.GitSource{URL: "https://example.com/site.git"}},
+			want:   "/usr/share/nginx/html/current",
+		},
+		{
+			name: "git subdirectory",
+			source: &webv1beta1.SourceSpec{Git: &webv1beta1.GitSource{
+				URL: "https://example.com/site.git", Subdirectory: "public",
+			}},
+			want: "/usr/share/nginx/html/current/public",
+		},
+		{
+			name:   "s3",
+			source: &webv1beta1.SourceSpec{S3: &webv1beta1.S3Source{Bucket: "site"}},
+			want:   "/usr/share/nginx/html/current",
+		},
+		{
+			name:   "archive",
+			source: &webv1beta1.SourceSpec{Archive: &webv1beta1.ArchiveSource{URL: "https://example.com/site.tgz"}},
+			want:   "/usr/share/nginx/html/current",
+		},
+		{
+			name:   "copied image",
+			source: &webv1beta1.SourceSpec{Image: &webv1beta1.ImageSource{Reference: "site:v1", Path: "/dist"}},
+			want:   "/usr/share/nginx/html",
+		},
+		{
+			name: "mounted image",
+			source: &webv1beta1.SourceSpec{Image: &webv1beta1.ImageSource{
+				Reference: "site:v1", Path: "/dist", Mount: webv1beta1.ImageMountVolume,
+			}},
+			want: "/usr/share/nginx/html/dist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Source = tt.source
+			site.Spec.Content.Revision = tt.revision
+			if got := contentRoot(site); got != tt.want {
+				t.Errorf("contentRoot() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
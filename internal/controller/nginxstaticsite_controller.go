@@ -1,451 +1,534 @@
 package controller
 
 import (
-    "context"
-
-    appsv1 "k8s.io/api/apps/v1"
-    corev1 "k8s.io/api/core/v1"
-    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-    "k8s.io/apimachinery/pkg/api/errors"
-    "k8s.io/apimachinery/pkg/runtime"
-    resource "k8s.io/apimachinery/pkg/api/resource"
-    ctrl "sigs.k8s.io/controller-runtime"
-    "sigs.k8s.io/controller-runtime/pkg/client"
-    "sigs.k8s.io/controller-runtime/pkg/log"
-    networkingv1 "k8s.io/api/networking/v1"
-    webv1alpha1 "github.com/m-nik/k8s-nginx-operator/api/v1alpha1"
-    intstr "k8s.io/apimachinery/pkg/util/intstr"
-    "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-    "time"
-    "github.com/prometheus/client_golang/prometheus"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
 )
 
-
 // prometheus metrics
 var (
-    activeDeployments = prometheus.NewGauge(
-        prometheus.GaugeOpts{
-            Name: "nginx_active_deployments",
-            Help: "Number of active Nginx deployments",
-        },
-    )
-    failedReconciliations = prometheus.NewCounter(
-        prometheus.CounterOpts{
-            Name: "nginx_failed_reconciliations_total",
-            Help: "Total number of failed reconciliation attempts",
-        },
-    )
-    totalStorageUsed = prometheus.NewGauge(
-        prometheus.GaugeOpts{
-            Name: "nginx_total_storage_bytes",
-            Help: "Total storage requested by Nginx PVCs (bytes)",
-        },
-    )
+	activeDeployments = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nginx_active_deployments",
+			Help: "Number of active Nginx deployments",
+		},
+	)
+	failedReconciliations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "nginx_failed_reconciliations_total",
+			Help: "Total number of failed reconciliation attempts",
+		},
+	)
+	totalStorageUsed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nginx_total_storage_bytes",
+			Help: "Total storage requested by Nginx PVCs (bytes)",
+		},
+	)
 )
 
-
 type NginxStaticSiteReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// PodProxy reaches the nginx pods through the API server to read the
+	// git-sync status. Git sync status is not reported when nil.
+	PodProxy corev1client.PodsGetter
+	// Recorder emits Events on the sites. No Events are emitted when nil.
+	Recorder record.EventRecorder
+	// CustomMetrics reaches the custom metrics API to read the canary error
+	// rate. spec.canary.maxErrorPercentage is not checked when nil.
+	CustomMetrics rest.Interface
+
+	// routesWatched is set when the Gateway API CRDs were found at startup
+	// and the HTTPRoutes are watched.
+	routesWatched bool
 }
 
 // Finalizer
 const finalizerName = "nginxstaticsite.finalizers.ictplus.ir"
 
-
+// fieldOwner is the field manager used for every server-side apply. Only the
+// fields set under it are enforced; fields owned by other managers are kept.
+const fieldOwner = client.FieldOwner("nginxstaticsite-operator")
 
 // Reconcile
 func (r *NginxStaticSiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-    logger := log.FromContext(ctx)
-
-    var site webv1alpha1.NginxStaticSite
-    if err := r.Get(ctx, req.NamespacedName, &site); err != nil {
-        if errors.IsNotFound(err) {
-            return ctrl.Result{}, nil
-        }
-        return ctrl.Result{}, err
-    }
-
-
-    // Handling deletion and finalizer
-    if site.ObjectMeta.DeletionTimestamp.IsZero() {
-        // Add finalizer
-        if !controllerutil.ContainsFinalizer(&site, finalizerName) {
-            controllerutil.AddFinalizer(&site, finalizerName)
-            if err := r.Update(ctx, &site); err != nil {
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        }
-    } else {
-        // Deleting
-        logger.Info("Cleaning up resources for deleted NginxStaticSite", "name", site.Name)
-    
-        // Delete Resources
-        _ = r.Delete(ctx, &appsv1.Deployment{
-            ObjectMeta: metav1.ObjectMeta{Name: site.Name + "-nginx", Namespace: site.Namespace},
-        })
-        _ = r.Delete(ctx, &corev1.Service{
-            ObjectMeta: metav1.ObjectMeta{Name: site.Name + "-svc", Namespace: site.Namespace},
-        })
-        _ = r.Delete(ctx, &networkingv1.Ingress{
-            ObjectMeta: metav1.ObjectMeta{Name: site.Name + "-ing", Namespace: site.Namespace},
-        })
-        _ = r.Delete(ctx, &corev1.PersistentVolumeClaim{
-            ObjectMeta: metav1.ObjectMeta{Name: site.Name + "-pvc", Namespace: site.Namespace},
-        })
-    
-        // Remove finalizer
-        controllerutil.RemoveFinalizer(&site, finalizerName)
-        if err := r.Update(ctx, &site); err != nil {
-            return ctrl.Result{}, err
-        }
-    
-        return ctrl.Result{}, nil
-    }
-    
-
-
-
-
-
-    site.Status.Phase = "Creating"
-    r.Status().Update(ctx, &site)
-
-
-
-    // ===== PVC =====
-    // ===============
-    pvc := &corev1.PersistentVolumeClaim{}
-    pvcName := site.Name + "-pvc"
-    err := r.Get(ctx, client.ObjectKey{Name: pvcName, Namespace: site.Namespace}, pvc)
-    
-    desiredSize := resourceMustParse(site.Spec.StorageSize)
-    totalStorageUsed.Set(float64(desiredSize.Value()))
-    
-    if err != nil && errors.IsNotFound(err) {
-        // PVC not exists, create
-        pvc = &corev1.PersistentVolumeClaim{
-            ObjectMeta: metav1.ObjectMeta{
-                Name:      pvcName,
-                Namespace: site.Namespace,
-            },
-            Spec: corev1.PersistentVolumeClaimSpec{
-                AccessModes: []corev1.PersistentVolumeAccessMode{
-                    corev1.ReadWriteOnce,
-                },
-                Resources: corev1.VolumeResourceRequirements{
-                    Requests: corev1.ResourceList{
-                        corev1.ResourceStorage: desiredSize,
-                    },
-                },
-            },
-        }
-    
-        if err := ctrl.SetControllerReference(&site, pvc, r.Scheme); err == nil {
-            if err := r.Create(ctx, pvc); err != nil {
-                logger.Error(err, "failed to create PVC")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-            logger.Info("Created PVC", "name", pvcName)
-        }
-    } else if err == nil {
-        // PVC exists, update
-        currentSize := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
-    
-        if desiredSize.Cmp(currentSize) > 0 {
-            pvc.Spec.Resources.Requests[corev1.ResourceStorage] = desiredSize
-            if err := r.Update(ctx, pvc); err != nil {
-                logger.Error(err, "failed to resize PVC")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-            logger.Info("Resized PVC", "name", pvcName)
-        }
-    } else {
-        // Unexpected error
-        logger.Error(err, "failed to get PVC")
-        site.Status.Phase = "Failed"
-        r.Status().Update(ctx, &site)
-        return ctrl.Result{}, err
-    }
-
-
-
-
-
-    // = Deployment ==
-    // ===============
-    existingDeploy := &appsv1.Deployment{}
-    err = r.Get(ctx, client.ObjectKey{
-        Name:      site.Name + "-nginx",
-        Namespace: site.Namespace,
-    }, existingDeploy)
-    
-    if err != nil && errors.IsNotFound(err) {
-        // Deployment not exists, create
-        deploy := &appsv1.Deployment{
-            ObjectMeta: metav1.ObjectMeta{
-                Name:      site.Name + "-nginx",
-                Namespace: site.Namespace,
-            },
-            Spec: appsv1.DeploymentSpec{
-                Replicas: &site.Spec.Replicas,
-                Selector: &metav1.LabelSelector{
-                    MatchLabels: map[string]string{"app": site.Name},
-                },
-                Template: corev1.PodTemplateSpec{
-                    ObjectMeta: metav1.ObjectMeta{
-                        Labels: map[string]string{"app": site.Name},
-                    },
-                    Spec: corev1.PodSpec{
-                        NodeSelector: site.Spec.NodeSelector,
-                        Containers: []corev1.Container{
-                            {
-                                Name:  "nginx",
-                                Image: "nginx:" + site.Spec.ImageVersion,
-                                VolumeMounts: []corev1.VolumeMount{
-                                    {
-                                        Name:      "static-content",
-                                        MountPath: site.Spec.StaticFilePath,
-                                    },
-                                },
-                            },
-                        },
-                        Volumes: []corev1.Volume{
-                            {
-                                Name: "static-content",
-                                VolumeSource: corev1.VolumeSource{
-                                    PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-                                        ClaimName: site.Name + "-pvc",
-                                    },
-                                },
-                            },
-                        },
-                    },
-                },
-            },
-        }
-    
-        if err := ctrl.SetControllerReference(&site, deploy, r.Scheme); err == nil {
-            if err := r.Create(ctx, deploy); err != nil {
-                logger.Error(err, "failed to create deployment")
-		failedReconciliations.Inc()
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        } else {
-	        activeDeployments.Set(1)
-	}
-
-    } else if err == nil {
-        // Deployment exists, update
-        updated := false
-    
-        if *existingDeploy.Spec.Replicas != site.Spec.Replicas {
-            existingDeploy.Spec.Replicas = &site.Spec.Replicas
-            updated = true
-        }
-    
-        container := &existingDeploy.Spec.Template.Spec.Containers[0]
-        desiredImage := "nginx:" + site.Spec.ImageVersion
-        if container.Image != desiredImage {
-            container.Image = desiredImage
-            updated = true
-        }
-    
-        if container.VolumeMounts[0].MountPath != site.Spec.StaticFilePath {
-            container.VolumeMounts[0].MountPath = site.Spec.StaticFilePath
-            updated = true
-        }
-    
-        if updated {
-            if err := r.Update(ctx, existingDeploy); err != nil {
-                logger.Error(err, "failed to update deployment")
+	logger := log.FromContext(ctx)
+
+	var site webv1beta1.NginxStaticSite
+	if err := r.Get(ctx, req.NamespacedName, &site); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Handling deletion and finalizer
+	if site.ObjectMeta.DeletionTimestamp.IsZero() {
+		// Add finalizer
+		if !controllerutil.ContainsFinalizer(&site, finalizerName) {
+			controllerutil.AddFinalizer(&site, finalizerName)
+			if err := r.Update(ctx, &site); err != nil {
+				r.markFailed(ctx, &site, webv1beta1.ConditionReady, webv1beta1.ReasonFinalizerFailed, err)
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		// Deleting
+		logger.Info("Cleaning up resources for deleted NginxStaticSite", "name", site.Name)
+
+		// Delete Resources
+		_ = r.Delete(ctx, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: deploymentName(&site), Namespace: site.Namespace},
+		})
+		_ = r.Delete(ctx, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: serviceName(&site), Namespace: site.Namespace},
+		})
+		_ = r.Delete(ctx, &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: ingressName(&site), Namespace: site.Namespace},
+		})
+		_ = r.Delete(ctx, &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: hpaName(&site), Namespace: site.Namespace},
+		})
+		_ = r.Delete(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: configMapName(&site), Namespace: site.Namespace},
+		})
+
+		// The PVC is handled last, once the workloads using it are being deleted.
+		done, err := r.finalizePVC(ctx, &site)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+
+		// Remove finalizer
+		controllerutil.RemoveFinalizer(&site, finalizerName)
+		if err := r.Update(ctx, &site); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// The rollback updates the site, which triggers the next reconcile.
+	if site.Annotations[rollbackAnnotation] != "" {
+		return ctrl.Result{}, r.rollback(ctx, &site)
+	}
+	// The spec update of a promotion did not go through. It is finished first
+	// so the promoted stack is not rolled back to the previous spec.
+	if status := site.Status.Release; status != nil && status.Phase == webv1beta1.ReleasePromoted &&
+		site.Spec.Release != nil {
+		return ctrl.Result{}, r.adoptRelease(ctx, &site)
+	}
+
+	if meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionReady) == nil {
+		setCondition(&site, webv1beta1.ConditionReady, metav1.ConditionUnknown, webv1beta1.ReasonReconciling, "Creating child resources")
+		if err := r.Status().Update(ctx, &site); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// ===== PVC =====
+	// ===============
+	if err := r.reconcilePVC(ctx, &site); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// == ConfigMap ==
+	// ===============
+	config, err := r.reconcileConfig(ctx, &site)
+	if err != nil {
+		logger.Error(err, "failed to apply nginx config")
+		r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonConfigFailed, err)
+		return ctrl.Result{}, err
+	}
+
+	// == Autoscaler =
+	// ===============
+	if site.Spec.Autoscaling != nil {
+		if err := r.apply(ctx, &site, desiredHPA(&site)); err != nil {
+			logger.Error(err, "failed to apply horizontal pod autoscaler")
+			r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonAutoscalerFailed, err)
+			return ctrl.Result{}, err
+		}
+	} else {
+		err := r.Delete(ctx, &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: hpaName(&site), Namespace: site.Namespace},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "failed to delete horizontal pod autoscaler")
+			r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonAutoscalerFailed, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// = Deployment ==
+	// ===============
+	contentChecksum, err := r.reconcileProjectedContent(ctx, &site)
+	if err != nil {
+		logger.Error(err, "failed to read content ConfigMap")
+		r.markFailed(ctx, &site, webv1beta1.ConditionContentReady, webv1beta1.ReasonSyncFailed, err)
+		return ctrl.Result{}, err
+	}
+	deploy := desiredDeployment(&site, configChecksum(config), contentChecksum)
+	if site.Spec.Autoscaling != nil {
+		if deploy.Spec.Replicas, err = r.autoscaledReplicas(ctx, &site); err != nil {
+			logger.Error(err, "failed to read horizontal pod autoscaler")
+			r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonAutoscalerFailed, err)
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.apply(ctx, &site, deploy); err != nil {
+		logger.Error(err, "failed to apply deployment")
 		failedReconciliations.Inc()
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-            logger.Info("Updated deployment successfully", "name", site.Name)
-	    activeDeployments.Set(1)
-        }
-    } else {
-        site.Status.Phase = "Failed"
-	failedReconciliations.Inc()
-        r.Status().Update(ctx, &site)
-        return ctrl.Result{}, err
-    }
-
-    // === Service ===
-    // ===============
-    svc := &corev1.Service{}
-    svcName := site.Name + "-svc"
-    err = r.Get(ctx, client.ObjectKey{Name: svcName, Namespace: site.Namespace}, svc)
-    
-    if err != nil && errors.IsNotFound(err) {
-        svc = &corev1.Service{
-            ObjectMeta: metav1.ObjectMeta{
-                Name:      svcName,
-                Namespace: site.Namespace,
-            },
-            Spec: corev1.ServiceSpec{
-                Selector: map[string]string{"app": site.Name},
-                Ports: []corev1.ServicePort{
-                    {
-                        Port:     80,
-                        Protocol: corev1.ProtocolTCP,
-                        TargetPort: intstr.FromInt(80),
-                    },
-                },
-                Type: corev1.ServiceTypeClusterIP,
-            },
-        }
-        if err := ctrl.SetControllerReference(&site, svc, r.Scheme); err == nil {
-            if err := r.Create(ctx, svc); err != nil {
-                logger.Error(err, "failed to create service")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        }
-    } else if err == nil {
-        updated := false
-        if svc.Spec.Type != corev1.ServiceTypeClusterIP {
-            svc.Spec.Type = corev1.ServiceTypeClusterIP
-            updated = true
-        }
-        if updated {
-            if err := r.Update(ctx, svc); err != nil {
-                logger.Error(err, "failed to update service")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        }
-    } else {
-        site.Status.Phase = "Failed"
-        r.Status().Update(ctx, &site)
-        return ctrl.Result{}, err
-    }
-
-
-    // === Ingress ===
-    // ===============
-    ing := &networkingv1.Ingress{}
-    ingName := site.Name + "-ing"
-    pathPrefix := "/" + site.Name
-    
-    err = r.Get(ctx, client.ObjectKey{Name: ingName, Namespace: site.Namespace}, ing)
-    if err != nil && errors.IsNotFound(err) {
-        ing = &networkingv1.Ingress{
-            ObjectMeta: metav1.ObjectMeta{
-                Name:      ingName,
-                Namespace: site.Namespace,
-            },
-            Spec: networkingv1.IngressSpec{
-                Rules: []networkingv1.IngressRule{
-                    {
-                        IngressRuleValue: networkingv1.IngressRuleValue{
-                            HTTP: &networkingv1.HTTPIngressRuleValue{
-                                Paths: []networkingv1.HTTPIngressPath{
-                                    {
-                                        Path:     pathPrefix,
-                                        PathType: func() *networkingv1.PathType { pt := networkingv1.PathTypePrefix; return &pt }(),
-                                        Backend: networkingv1.IngressBackend{
-                                            Service: &networkingv1.IngressServiceBackend{
-                                                Name: svcName,
-                                                Port: networkingv1.ServiceBackendPort{
-                                                    Number: 80,
-                                                },
-                                            },
-                                        },
-                                    },
-                                },
-                            },
-                        },
-                    },
-                },
-            },
-        }
-    
-        if err := ctrl.SetControllerReference(&site, ing, r.Scheme); err == nil {
-            if err := r.Create(ctx, ing); err != nil {
-                logger.Error(err, "failed to create ingress")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        }
-    } else if err == nil {
-        updated := false
-        paths := ing.Spec.Rules[0].IngressRuleValue.HTTP.Paths
-        if len(paths) == 0 || paths[0].Path != pathPrefix {
-            ing.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Path = pathPrefix
-            updated = true
-        }
-        if updated {
-            if err := r.Update(ctx, ing); err != nil {
-                logger.Error(err, "failed to update ingress")
-                site.Status.Phase = "Failed"
-                r.Status().Update(ctx, &site)
-                return ctrl.Result{}, err
-            }
-        }
-    } else {
-        site.Status.Phase = "Failed"
-        r.Status().Update(ctx, &site)
-        return ctrl.Result{}, err
-    }
-    
-
-
-
-    // Self-healing
-    podList := &corev1.PodList{}
-    _ = r.List(ctx, podList, client.InNamespace(site.Namespace), client.MatchingLabels{"app": site.Name})
-
-    readyCount := int32(0)
-    for _, pod := range podList.Items {
-        for _, cond := range pod.Status.Conditions {
-            if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
-                readyCount++
-            }
-        }
-    }
-
-    site.Status.ReadyReplicas = readyCount
-    if readyCount < site.Spec.Replicas {
-        site.Status.Phase = "Pending"
-	// Exponential backoff
-        return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
-    } else {
-        site.Status.Phase = "Running"
-    }
-    r.Status().Update(ctx, &site)
-
-    //logger.Info("Reconciled NginxStaticSite successfully", "name", site.Name)
-
-    return ctrl.Result{}, nil
+		r.markFailed(ctx, &site, webv1beta1.ConditionDeploymentAvailable, webv1beta1.ReasonDeploymentFailed, err)
+		return ctrl.Result{}, err
+	}
+	activeDeployments.Set(1)
+	setDeploymentCondition(&site, deploy)
+
+	// === Content ===
+	// ===============
+	requeueAfter, err := r.reconcileContent(ctx, &site)
+	if err != nil {
+		logger.Error(err, "failed to sync content")
+		r.markFailed(ctx, &site, webv1beta1.ConditionContentReady, webv1beta1.ReasonSyncFailed, err)
+		return ctrl.Result{}, err
+	}
+
+	// === Release ===
+	// ===============
+	if promoted, err := r.reconcileRelease(ctx, &site); err != nil || promoted {
+		if err != nil {
+			logger.Error(err, "failed to reconcile release")
+			r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonReleaseFailed, err)
+		}
+		return ctrl.Result{}, err
+	}
+
+	// === Canary ====
+	// ===============
+	canaryAfter, promoted, err := r.reconcileCanary(ctx, &site)
+	if err != nil || promoted {
+		if err != nil {
+			logger.Error(err, "failed to reconcile canary")
+			r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonCanaryFailed, err)
+		}
+		return ctrl.Result{}, err
+	}
+	if canaryAfter > 0 && (requeueAfter == 0 || canaryAfter < requeueAfter) {
+		requeueAfter = canaryAfter
+	}
+
+	// === Service ===
+	// ===============
+	svc := desiredService(&site)
+	if err := r.apply(ctx, &site, svc); err != nil {
+		logger.Error(err, "failed to apply service")
+		r.markFailed(ctx, &site, webv1beta1.ConditionServiceReady, webv1beta1.ReasonServiceFailed, err)
+		return ctrl.Result{}, err
+	}
+	setCondition(&site, webv1beta1.ConditionServiceReady, metav1.ConditionTrue, webv1beta1.ReasonReconciled,
+		"Service "+svc.Name+" is reconciled")
+
+	// === Routing ===
+	// ===============
+	if gatewayRouting(&site) {
+		if err := r.reconcileHTTPRoute(ctx, &site); err != nil {
+			logger.Error(err, "failed to apply http route")
+			r.markFailed(ctx, &site, webv1beta1.ConditionRouteAccepted, webv1beta1.ReasonRouteFailed, err)
+			return ctrl.Result{}, err
+		}
+		// Without a watch, the route status is only picked up by polling.
+		if !r.routesWatched && (requeueAfter == 0 || routePollInterval < requeueAfter) {
+			requeueAfter = routePollInterval
+		}
+	} else {
+		if err := r.removeHTTPRoute(ctx, &site); err != nil {
+			logger.Error(err, "failed to delete http route")
+			r.markFailed(ctx, &site, webv1beta1.ConditionRouteAccepted, webv1beta1.ReasonRouteFailed, err)
+			return ctrl.Result{}, err
+		}
+		ing := desiredIngress(&site)
+		if err := r.apply(ctx, &site, ing); err != nil {
+			logger.Error(err, "failed to apply ingress")
+			r.markFailed(ctx, &site, webv1beta1.ConditionIngressReady, webv1beta1.ReasonIngressFailed, err)
+			return ctrl.Result{}, err
+		}
+		setIngressCondition(&site, ing)
+	}
+
+	// Self-healing
+	podList := &corev1.PodList{}
+	_ = r.List(ctx, podList, client.InNamespace(site.Namespace), client.MatchingLabels(selectorLabels(&site)))
+
+	readyCount := int32(0)
+	for i := range podList.Items {
+		if podReady(&podList.Items[i]) {
+			readyCount++
+		}
+	}
+	r.updateGitStatus(ctx, &site, podList.Items)
+	updateImageStatus(&site, podList.Items)
+
+	if err := r.recordRevision(ctx, &site, contentChecksum); err != nil {
+		logger.Error(err, "failed to record revision")
+		r.markFailed(ctx, &site, webv1beta1.ConditionDegraded, webv1beta1.ReasonHistoryFailed, err)
+		return ctrl.Result{}, err
+	}
+
+	site.Status.ReadyReplicas = readyCount
+	site.Status.Selector = labels.SelectorFromSet(selectorLabels(&site)).String()
+	site.Status.ObservedGeneration = site.Generation
+	setCondition(&site, webv1beta1.ConditionDegraded, metav1.ConditionFalse, webv1beta1.ReasonAsExpected,
+		"All child resources reconciled")
+	// While autoscaling, the HPA decides the replica count on the Deployment.
+	replicas := desiredReplicas(&site)
+	if site.Spec.Autoscaling != nil && deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	ready := setReadyCondition(&site, readyCount, replicas)
+	if err := r.Status().Update(ctx, &site); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		// Exponential backoff
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+	if git := gitSource(&site); git != nil {
+		// Pick up the commits git-sync checks out on its own.
+		if interval := pollInterval(git); requeueAfter == 0 || interval < requeueAfter {
+			requeueAfter = interval
+		}
+	}
+
+	//logger.Info("Reconciled NginxStaticSite successfully", "name", site.Name)
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcilePVC applies the claim holding the site content, or checks the
+// existing claim it was pointed at, and reports StorageReady. The condition is
+// dropped when the content lives elsewhere. Changes the claim cannot take in
+// place are carried out as a migration to a new claim when allowed.
+func (r *NginxStaticSiteReconciler) reconcilePVC(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if !usesPVC(site) {
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionStorageReady)
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionStorageResizing)
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	if claim := site.Spec.Storage.ExistingClaim; claim != "" {
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionStorageResizing)
+		existing := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, client.ObjectKey{Name: claim, Namespace: site.Namespace}, existing)
+		if errors.IsNotFound(err) {
+			setCondition(site, webv1beta1.ConditionStorageReady, metav1.ConditionFalse, webv1beta1.ReasonPVCPending,
+				"PVC "+claim+" not found")
+			return nil
+		}
+		if err != nil {
+			logger.Error(err, "failed to get existing PVC")
+			r.markFailed(ctx, site, webv1beta1.ConditionStorageReady, webv1beta1.ReasonPVCFailed, err)
+			return err
+		}
+		setStorageCondition(site, existing)
+		return nil
+	}
+
+	desiredSize := resourceMustParse(site.Spec.Storage.Size)
+	totalStorageUsed.Set(float64(desiredSize.Value()))
+
+	existingPVC := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Name: contentClaimName(site), Namespace: site.Namespace}, existingPVC)
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "failed to get PVC")
+		r.markFailed(ctx, site, webv1beta1.ConditionStorageReady, webv1beta1.ReasonPVCFailed, err)
+		return err
+	}
+	if err == nil && site.Spec.Storage.AllowMigration && needsMigration(site, existingPVC, desiredSize) {
+		// The current claim keeps serving, untouched, until the copy is done.
+		setStorageCondition(site, existingPVC)
+		if err := r.reconcileMigration(ctx, site, existingPVC, desiredSize); err != nil {
+			logger.Error(err, "failed to migrate PVC")
+			r.markFailed(ctx, site, webv1beta1.ConditionStorageMigrating, webv1beta1.ReasonMigrationFailed, err)
+			return err
+		}
+		return nil
+	}
+	shrinkMessage := ""
+	if err == nil {
+		// PVCs can only grow, so keep the current request rather than have the
+		// API server reject the apply, and report the shrink request instead.
+		currentSize := existingPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+		if desiredSize.Cmp(currentSize) < 0 {
+			shrinkMessage = fmt.Sprintf("Requested size %s is smaller than the current %s of PVC %s; PVCs cannot shrink",
+				desiredSize.String(), currentSize.String(), existingPVC.Name)
+			desiredSize = currentSize
+		}
+	}
+
+	pvc := desiredPVC(site, contentClaimName(site), desiredSize)
+	if err := r.apply(ctx, site, pvc); err != nil {
+		logger.Error(err, "failed to apply PVC")
+		r.markFailed(ctx, site, webv1beta1.ConditionStorageReady, webv1beta1.ReasonPVCFailed, err)
+		return err
+	}
+	setStorageCondition(site, pvc)
+	setResizeCondition(site, pvc)
+	if shrinkMessage != "" {
+		setCondition(site, webv1beta1.ConditionStorageReady, metav1.ConditionFalse,
+			webv1beta1.ReasonStorageShrinkNotSupported, shrinkMessage)
+	}
+	if err := r.finishMigration(ctx, site); err != nil {
+		logger.Error(err, "failed to finish PVC migration")
+		r.markFailed(ctx, site, webv1beta1.ConditionStorageMigrating, webv1beta1.ReasonMigrationFailed, err)
+		return err
+	}
+	return nil
+}
+
+// apply makes obj controlled by the site and server-side applies it under
+// fieldOwner, taking over conflicting fields. On success obj holds the live
+// object returned by the API server.
+func (r *NginxStaticSiteReconciler) apply(ctx context.Context, site *webv1beta1.NginxStaticSite, obj client.Object) error {
+	if err := ctrl.SetControllerReference(owner(site), obj, r.Scheme); err != nil {
+		return err
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return r.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+}
+
+// autoscaledReplicas returns the Deployment replicas to apply while the HPA
+// takes them over. Leaving the field out of the apply while the operator is
+// its only owner would make the API server default it to 1, so the current
+// count is kept until the HPA has scaled the Deployment, from then on the
+// field is left out. A Deployment promoted from a release has not been
+// scaled by the HPA yet, even though the HPA scaled its predecessor.
+func (r *NginxStaticSiteReconciler) autoscaledReplicas(ctx context.Context, site *webv1beta1.NginxStaticSite) (*int32, error) {
+	current := &appsv1.Deployment{}
+	err := r.Get(ctx, client.ObjectKey{Name: deploymentName(site), Namespace: site.Namespace}, current)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && current.Spec.Replicas != nil {
+		if replicasScaledElsewhere(current) {
+			return nil, nil
+		}
+		return current.Spec.Replicas, nil
+	}
+	return ptr.To(max(desiredReplicas(site), ptr.Deref(site.Spec.Autoscaling.MinReplicas, 1))), nil
+}
+
+// replicasScaledElsewhere reports whether a field manager other than the
+// operator, such as the HPA, owns the replicas of the Deployment.
+func replicasScaledElsewhere(deploy *appsv1.Deployment) bool {
+	for _, entry := range deploy.ManagedFields {
+		if entry.Manager == string(fieldOwner) || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Spec map[string]any `json:"f:spec"`
+		}
+		if json.Unmarshal(entry.FieldsV1.Raw, &fields) != nil {
+			continue
+		}
+		if _, ok := fields.Spec["f:replicas"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// event records an Event on the site when a Recorder is set.
+func (r *NginxStaticSiteReconciler) event(site *webv1beta1.NginxStaticSite, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(site, eventType, reason, message)
+	}
+}
+
+// podReady reports whether the pod passes its readiness checks.
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
 }
 
 // helper to parse storage size
 func resourceMustParse(size string) resource.Quantity {
-    q, _ := resource.ParseQuantity(size)
-    return q
+	q, _ := resource.ParseQuantity(size)
+	return q
 }
 
 func (r *NginxStaticSiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
-    
-    prometheus.MustRegister(activeDeployments, failedReconciliations, totalStorageUsed)
-    
-    return ctrl.NewControllerManagedBy(mgr).
-        For(&webv1alpha1.NginxStaticSite{}).
-        Complete(r)
-}
 
+	prometheus.MustRegister(activeDeployments, failedReconciliations, totalStorageUsed)
+
+	// Watching the owned children lets the operator repair edits and
+	// deletions made behind its back instead of waiting for the site to change.
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&webv1beta1.NginxStaticSite{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&batchv1.Job{}).
+		Owns(&webv1beta1.NginxStaticSiteRevision{}).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.sitesForConfigMap)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.sitesForSecret))
+
+	// HTTPRoutes can only be watched where the Gateway API CRDs are installed.
+	// Elsewhere sites using them poll their route (see routePollInterval).
+	_, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version)
+	switch {
+	case err == nil:
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		b = b.Owns(route)
+		r.routesWatched = true
+	case !meta.IsNoMatchError(err):
+		return err
+	}
+	return b.Complete(r)
+}
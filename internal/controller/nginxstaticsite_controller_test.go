@@ -0,0 +1,59 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicasScaledElsewhere(t *testing.T) {
+	replicas := metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}
+	template := metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)}
+	tests := []struct {
+		name   string
+		fields []metav1.ManagedFieldsEntry
+		want   bool
+	}{
+		{
+			name:   "operator only",
+			fields: []metav1.ManagedFieldsEntry{{Manager: string(fieldOwner), FieldsV1: &replicas}},
+		},
+		{
+			name: "scaled by the HPA",
+			fields: []metav1.ManagedFieldsEntry{
+				{Manager: string(fieldOwner), FieldsV1: &template},
+				{Manager: "kube-controller-manager", Subresource: "scale", FieldsV1: &replicas},
+			},
+			want: true,
+		},
+		{
+			name:   "other fields of another manager",
+			fields: []metav1.ManagedFieldsEntry{{Manager: "kubectl-edit", FieldsV1: &template}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{ManagedFields: tt.fields}}
+			if got := replicasScaledElsewhere(deploy); got != tt.want {
+				t.Errorf("replicasScaledElsewhere() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
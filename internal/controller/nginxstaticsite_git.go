@@ -0,0 +1,211 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// git-sync keeps the site volume at the requested ref. Its exechook records
+// every synced commit in a status file on the volume, which nginx serves on an
+// internal port and the operator reads through the API server pod proxy.
+const (
+	gitSyncImage = "registry.k8s.io/git-sync/git-sync:v4.4.0"
+	// gitSyncGroup is the group git-sync runs as; the pod fsGroup lets it
+	// write to the site volume.
+	gitSyncGroup = 65533
+	gitRoot      = "/git"
+	gitSecretDir = "/etc/git-secret"
+	// syncStatusScript is the key of the exechook in the site ConfigMap.
+	syncStatusScript = "sync-status.sh"
+	syncScriptDir    = "/etc/git-sync"
+	syncStatusFile   = ".sync-status"
+	syncStatusPort   = 8081
+
+	defaultPollInterval = time.Minute
+)
+
+// syncStatusHook writes "<sha> <time>" next to the checkout after each sync.
+const syncStatusHook = `#!/bin/sh
+printf '%s %s\n' "$GITSYNC_HASH" "$(date -u +%Y-%m-%dT%H:%M:%SZ)" > ` + gitRoot + "/" + syncStatusFile + `.tmp
+mv ` + gitRoot + "/" + syncStatusFile + ".tmp " + gitRoot + "/" + syncStatusFile + "\n"
+
+// gitSource returns spec.source.git, or nil when the site is not synced from git.
+func gitSource(site *webv1beta1.NginxStaticSite) *webv1beta1.GitSource {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.Git
+}
+
+// pollInterval returns how often git-sync checks the repository.
+func pollInterval(git *webv1beta1.GitSource) time.Duration {
+	if git.PollInterval == nil {
+		return defaultPollInterval
+	}
+	return git.PollInterval.Duration
+}
+
+// gitSyncContainer builds a git-sync container writing to the site volume.
+// The init variant syncs once so nginx starts with content in place.
+func gitSyncContainer(site *webv1beta1.NginxStaticSite, name string, oneTime bool) corev1.Container {
+	git := site.Spec.Source.Git
+	ref := git.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	args := []string{
+		"--repo=" + git.URL,
+		"--ref=" + ref,
+		"--root=" + gitRoot,
+		"--link=" + currentLink,
+		"--period=" + pollInterval(git).String(),
+		"--exechook-command=" + syncScriptDir + "/" + syncStatusScript,
+		"--group-write",
+	}
+	if oneTime {
+		args = append(args, "--one-time")
+	}
+
+	container := corev1.Container{
+		Name:  name,
+		Image: gitSyncImage,
+		Args:  args,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "static-content", MountPath: gitRoot},
+			{Name: "git-sync-hook", MountPath: syncScriptDir, ReadOnly: true},
+		},
+	}
+	if ref := git.CredentialsSecretRef; ref != nil {
+		if isSSHURL(git.URL) {
+			container.Args = append(container.Args,
+				"--ssh-key-file="+gitSecretDir+"/ssh-privatekey",
+				"--ssh-known-hosts-file="+gitSecretDir+"/known_hosts")
+			container.VolumeMounts = append(container.VolumeMounts,
+				corev1.VolumeMount{Name: "git-secret", MountPath: gitSecretDir, ReadOnly: true})
+		} else {
+			container.Env = []corev1.EnvVar{
+				secretEnv("GITSYNC_USERNAME", ref.Name, "username"),
+				secretEnv("GITSYNC_PASSWORD", ref.Name, "password"),
+			}
+		}
+	}
+	return container
+}
+
+// gitSyncVolumes are the volumes the git-sync containers need besides the site volume.
+func gitSyncVolumes(site *webv1beta1.NginxStaticSite) []corev1.Volume {
+	git := site.Spec.Source.Git
+	volumes := []corev1.Volume{
+		{
+			Name: "git-sync-hook",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(site)},
+					Items:                []corev1.KeyToPath{{Key: syncStatusScript, Path: syncStatusScript}},
+					DefaultMode:          ptr.To[int32](0o555),
+				},
+			},
+		},
+	}
+	if git.CredentialsSecretRef != nil && isSSHURL(git.URL) {
+		volumes = append(volumes, corev1.Volume{
+			Name: "git-secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  git.CredentialsSecretRef.Name,
+					DefaultMode: ptr.To[int32](0o440),
+				},
+			},
+		})
+	}
+	return volumes
+}
+
+// isSSHURL reports whether the repository is cloned over SSH rather than HTTP(S).
+func isSSHURL(url string) bool {
+	return !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://")
+}
+
+func secretEnv(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+				Optional:             ptr.To(true),
+			},
+		},
+	}
+}
+
+// updateGitStatus records the most recent sync reported by the ready pods.
+// Pods that cannot be reached are skipped; the status is kept as is when none answer.
+func (r *NginxStaticSiteReconciler) updateGitStatus(ctx context.Context, site *webv1beta1.NginxStaticSite, pods []corev1.Pod) {
+	if gitSource(site) == nil {
+		if site.Status.Source != nil {
+			site.Status.Source.Git = nil
+		}
+		return
+	}
+	if r.PodProxy == nil {
+		return
+	}
+
+	var latest *webv1beta1.GitSourceStatus
+	for i := range pods {
+		if !podReady(&pods[i]) {
+			continue
+		}
+		raw, err := r.PodProxy.Pods(site.Namespace).
+			ProxyGet("http", pods[i].Name, strconv.Itoa(syncStatusPort), syncStatusFile, nil).
+			DoRaw(ctx)
+		if err != nil {
+			log.FromContext(ctx).V(1).Info("failed to read git-sync status", "pod", pods[i].Name, "error", err.Error())
+			continue
+		}
+		fields := strings.Fields(string(raw))
+		if len(fields) != 2 {
+			continue
+		}
+		synced, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			continue
+		}
+		if latest == nil || latest.LastSyncTime.Time.Before(synced) {
+			latest = &webv1beta1.GitSourceStatus{Commit: fields[0], LastSyncTime: &metav1.Time{Time: synced}}
+		}
+	}
+	if latest == nil {
+		return
+	}
+	if site.Status.Source == nil {
+		site.Status.Source = &webv1beta1.SourceStatus{}
+	}
+	site.Status.Source.Git = latest
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// The site history is kept as NginxStaticSiteRevisions owned by the site,
+// one per change of the spec or of the content served.
+const (
+	defaultHistoryLimit = 10
+	// historySiteLabel marks the NginxStaticSiteRevisions of a site.
+	historySiteLabel = "web.ictplus.ir/site"
+	// rollbackAnnotation on the site restores the spec of the named revision.
+	rollbackAnnotation = "web.ictplus.ir/rollback-to"
+)
+
+// Reasons of the Events emitted for the site history.
+const (
+	eventRevisionRecorded = "RevisionRecorded"
+	eventRolledBack       = "RolledBack"
+	eventRollbackFailed   = "RollbackFailed"
+)
+
+// historySpec is the part of the site spec kept in the history. It leaves
+// out the replica count, which scaling changes without changing what is
+// served, and the staged release and canary, which only change it once
+// promoted.
+func historySpec(site *webv1beta1.NginxStaticSite) *webv1beta1.NginxStaticSiteSpec {
+	spec := site.Spec.DeepCopy()
+	spec.Replicas = nil
+	spec.Release = nil
+	spec.Canary = nil
+	return spec
+}
+
+// specHash identifies the history spec of the site.
+func specHash(site *webv1beta1.NginxStaticSite) (string, error) {
+	data, err := json.Marshal(historySpec(site))
+	if err != nil {
+		return "", err
+	}
+	return configChecksum(string(data)), nil
+}
+
+// contentDigest identifies the content served, reporting false while the
+// source has not told yet. contentChecksum is that of a configMapRef or
+// secretRef source.
+func contentDigest(site *webv1beta1.NginxStaticSite, contentChecksum string) (string, bool) {
+	status := ptr.Deref(site.Status.Source, webv1beta1.SourceStatus{})
+	switch {
+	case gitSource(site) != nil:
+		if status.Git == nil || status.Git.Commit == "" {
+			return "", false
+		}
+		return "git:" + status.Git.Commit, true
+	case imageSource(site) != nil:
+		if status.Image != nil && status.Image.Digest != "" {
+			return status.Image.Digest, true
+		}
+		// Image volumes report no digest and are served as referenced.
+		return site.Spec.Source.Image.Reference, imageVolumeMount(site.Spec.Source.Image)
+	case configMapSource(site) != nil:
+		return "configmap:" + contentChecksum, contentChecksum != ""
+	case secretSource(site) != nil:
+		return "secret:" + contentChecksum, contentChecksum != ""
+	case revisioned(site) && !fetchesAtStartup(site):
+		if site.Status.Content == nil || site.Status.Content.Revision == "" {
+			return "", false
+		}
+		return "revision:" + site.Status.Content.Revision, true
+	case archiveSource(site) != nil:
+		return "sha256:" + site.Spec.Source.Archive.SHA256, true
+	}
+	// S3 content fetched at startup and content filled by hand have no digest.
+	return "", true
+}
+
+// recordRevision writes a NginxStaticSiteRevision when the spec or content
+// differs from the latest one, prunes the history to spec.historyLimit and
+// reports the latest revision in status.currentRevision.
+func (r *NginxStaticSiteReconciler) recordRevision(ctx context.Context, site *webv1beta1.NginxStaticSite, contentChecksum string) error {
+	digest, known := contentDigest(site, contentChecksum)
+	if !known {
+		return nil
+	}
+	hash, err := specHash(site)
+	if err != nil {
+		return err
+	}
+
+	var list webv1beta1.NginxStaticSiteRevisionList
+	if err := r.List(ctx, &list, client.InNamespace(site.Namespace),
+		client.MatchingLabels{historySiteLabel: site.Name}); err != nil {
+		return err
+	}
+	revisions := list.Items
+	slices.SortFunc(revisions, func(a, b webv1beta1.NginxStaticSiteRevision) int {
+		return cmp.Compare(a.Spec.Revision, b.Spec.Revision)
+	})
+
+	image := "nginx:" + site.Spec.Pod.ImageVersion
+	if n := len(revisions); n == 0 || revisions[n-1].Spec.SpecHash != hash ||
+		revisions[n-1].Spec.ContentDigest != digest || revisions[n-1].Spec.Image != image {
+		number := int64(1)
+		if n > 0 {
+			number = revisions[n-1].Spec.Revision + 1
+		}
+		revision := &webv1beta1.NginxStaticSiteRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      site.Name + "-rev-" + strconv.FormatInt(number, 10),
+				Namespace: site.Namespace,
+				Labels:    map[string]string{historySiteLabel: site.Name},
+			},
+			Spec: webv1beta1.NginxStaticSiteRevisionSpec{
+				SiteName:      site.Name,
+				Revision:      number,
+				SpecHash:      hash,
+				ContentDigest: digest,
+				Image:         image,
+				Timestamp:     metav1.Now(),
+				Spec:          *historySpec(site),
+			},
+		}
+		if err := r.apply(ctx, site, revision); err != nil {
+			return err
+		}
+		r.event(site, corev1.EventTypeNormal, eventRevisionRecorded, "Recorded revision "+revision.Name)
+		revisions = append(revisions, *revision)
+	}
+	site.Status.CurrentRevision = revisions[len(revisions)-1].Name
+
+	limit := int(ptr.Deref(site.Spec.HistoryLimit, defaultHistoryLimit))
+	for i := 0; i < len(revisions)-limit; i++ {
+		if err := r.Delete(ctx, &revisions[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback restores the spec recorded in the revision named by the rollback
+// annotation and removes the annotation. The replica count and autoscaler
+// are kept, and any staged release or canary is dropped, so the rollback
+// does not start a rollout of its own. A rollback that cannot be carried out
+// is reported as a Warning Event.
+func (r *NginxStaticSiteReconciler) rollback(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	name := site.Annotations[rollbackAnnotation]
+	revision := &webv1beta1.NginxStaticSiteRevision{}
+	err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: site.Namespace}, revision)
+	switch {
+	case errors.IsNotFound(err):
+		err = fmt.Errorf("revision %s not found", name)
+	case err != nil:
+		return err
+	case revision.Spec.SiteName != site.Name:
+		err = fmt.Errorf("revision %s belongs to site %s", name, revision.Spec.SiteName)
+	}
+
+	original := site.DeepCopy()
+	delete(site.Annotations, rollbackAnnotation)
+	if err == nil {
+		replicas, autoscaling := site.Spec.Replicas, site.Spec.Autoscaling
+		site.Spec = *revision.Spec.Spec.DeepCopy()
+		site.Spec.Replicas = replicas
+		site.Spec.Autoscaling = autoscaling
+		// A rollback restores what was served. A staged release or canary
+		// would start a rollout of its own, so neither is restored.
+		site.Spec.Release = nil
+		site.Spec.Canary = nil
+		if err = r.Update(ctx, site); err == nil {
+			r.event(site, corev1.EventTypeNormal, eventRolledBack, "Rolled back to revision "+name)
+			return nil
+		}
+		if errors.IsConflict(err) {
+			return err
+		}
+		// Drop the annotation alone, for instance when the webhook rejects
+		// the recorded spec today.
+		site.Spec = original.Spec
+		site.ResourceVersion = original.ResourceVersion
+	}
+	r.event(site, corev1.EventTypeWarning, eventRollbackFailed, "Cannot roll back to "+name+": "+err.Error())
+	return r.Patch(ctx, site, client.MergeFrom(original))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestSpecHash(t *testing.T) {
+	base, err := specHash(testSite())
+	if err != nil {
+		t.Fatalf("specHash() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		mutate  func(*webv1beta1.NginxStaticSite)
+		changed bool
+	}{
+		{
+			name:   "scaled",
+			mutate: func(site *webv1beta1.NginxStaticSite) { site.Spec.Replicas = ptr.To[int32](5) },
+		},
+		{
+			name: "release staged",
+			mutate: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Release = &webv1beta1.ReleaseSpec{ImageVersion: "1.27.1"}
+			},
+		},
+		{
+			name: "canary staged",
+			mutate: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Canary = &webv1beta1.CanarySpec{ImageVersion: "1.27.1"}
+			},
+		},
+		{
+			name:    "image changed",
+			mutate:  func(site *webv1beta1.NginxStaticSite) { site.Spec.Pod.ImageVersion = "1.27.1" },
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			tt.mutate(site)
+			got, err := specHash(site)
+			if err != nil {
+				t.Fatalf("specHash() error = %v", err)
+			}
+			if (got != base) != tt.changed {
+				t.Errorf("specHash() = %s, base %s, want changed %v", got, base, tt.changed)
+			}
+		})
+	}
+}
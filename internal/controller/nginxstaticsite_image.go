@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+const (
+	// imageCopyContainer is the init container copying the content out of the image.
+	imageCopyContainer = "content-copy"
+	// imageCopyMountPath is where it mounts the site volume.
+	imageCopyMountPath = "/mnt/site-content"
+)
+
+// imageSource returns spec.source.image, or nil when the site is not served from an image.
+func imageSource(site *webv1beta1.NginxStaticSite) *webv1beta1.ImageSource {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.Image
+}
+
+// imageVolumeMount reports whether the image is mounted as an image volume
+// rather than copied into an emptyDir.
+func imageVolumeMount(image *webv1beta1.ImageSource) bool {
+	return image.Mount == webv1beta1.ImageMountVolume
+}
+
+// imagePath returns the directory inside the image holding the site.
+func imagePath(image *webv1beta1.ImageSource) string {
+	if image.Path == "" {
+		return "/"
+	}
+	return path.Clean(image.Path)
+}
+
+// pinnedImage returns the image reference the pods run: the digest recorded
+// in status once the reference was resolved, the reference as given before.
+func pinnedImage(site *webv1beta1.NginxStaticSite) string {
+	image := imageSource(site)
+	if strings.Contains(image.Reference, "@") {
+		return image.Reference
+	}
+	status := site.Status.Source
+	if status == nil || status.Image == nil || status.Image.Reference != image.Reference || status.Image.Digest == "" {
+		return image.Reference
+	}
+	return imageRepository(image.Reference) + "@" + status.Image.Digest
+}
+
+// imageRepository strips the tag from an image reference, leaving a
+// registry port in place.
+func imageRepository(reference string) string {
+	name := reference[strings.LastIndex(reference, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return reference[:len(reference)-len(name)+i]
+	}
+	return reference
+}
+
+// imageCopyInitContainer runs the image once to copy its content into the site volume.
+func imageCopyInitContainer(site *webv1beta1.NginxStaticSite) corev1.Container {
+	image := imageSource(site)
+	return corev1.Container{
+		Name:    imageCopyContainer,
+		Image:   pinnedImage(site),
+		Command: []string{"cp", "-R", strings.TrimSuffix(imagePath(image), "/") + "/.", imageCopyMountPath},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "static-content", MountPath: imageCopyMountPath},
+		},
+	}
+}
+
+// updateImageStatus records the digest the image reference resolved to, as
+// reported by the container runtime for the copy init container. An image
+// volume reports no digest, so it is only known when the reference pins one.
+func updateImageStatus(site *webv1beta1.NginxStaticSite, pods []corev1.Pod) {
+	image := imageSource(site)
+	if image == nil {
+		if site.Status.Source != nil {
+			site.Status.Source.Image = nil
+		}
+		return
+	}
+	if site.Status.Source == nil {
+		site.Status.Source = &webv1beta1.SourceStatus{}
+	}
+	status := site.Status.Source.Image
+	if status == nil || status.Reference != image.Reference {
+		status = &webv1beta1.ImageSourceStatus{Reference: image.Reference}
+		site.Status.Source.Image = status
+	}
+	if i := strings.LastIndex(image.Reference, "@"); i >= 0 {
+		status.Digest = image.Reference[i+1:]
+		return
+	}
+	if status.Digest != "" || imageVolumeMount(image) {
+		return
+	}
+
+	for i := range pods {
+		for _, cs := range pods[i].Status.InitContainerStatuses {
+			if cs.Name != imageCopyContainer || cs.State.Terminated == nil || cs.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			// Only pods started from the unpinned reference tell what it resolves to.
+			if !initContainerRuns(&pods[i], imageCopyContainer, image.Reference) {
+				continue
+			}
+			if at := strings.LastIndex(cs.ImageID, "@"); at >= 0 {
+				status.Digest = cs.ImageID[at+1:]
+				return
+			}
+		}
+	}
+}
+
+// initContainerRuns reports whether the named init container of the pod runs image.
+func initContainerRuns(pod *corev1.Pod, name, image string) bool {
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == name {
+			return c.Image == image
+		}
+	}
+	return false
+}
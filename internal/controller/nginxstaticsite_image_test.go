@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestImageRepository(t *testing.T) {
+	tests := []struct {
+		reference, want string
+	}{
+		{"nginx", "nginx"},
+		{"nginx:1.27", "nginx"},
+		{"ghcr.io/acme/site:v2", "ghcr.io/acme/site"},
+		{"registry.local:5000/site", "registry.local:5000/site"},
+		{"registry.local:5000/team/site:latest", "registry.local:5000/team/site"},
+	}
+	for _, tt := range tests {
+		if got := imageRepository(tt.reference); got != tt.want {
+			t.Errorf("imageRepository(%q) = %q, want %q", tt.reference, got, tt.want)
+		}
+	}
+}
+
+func TestPinnedImage(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name      string
+		reference string
+		status    *webv1beta1.ImageSourceStatus
+		want      string
+	}{
+		{
+			name:      "not resolved yet",
+			reference: "ghcr.io/acme/site:v2",
+			want:      "ghcr.io/acme/site:v2",
+		},
+		{
+			name:      "resolved",
+			reference: "ghcr.io/acme/site:v2",
+			status:    &webv1beta1.ImageSourceStatus{Reference: "ghcr.io/acme/site:v2", Digest: digest},
+			want:      "ghcr.io/acme/site@" + digest,
+		},
+		{
+			name:      "resolved for another reference",
+			reference: "ghcr.io/acme/site:v3",
+			status:    &webv1beta1.ImageSourceStatus{Reference: "ghcr.io/acme/site:v2", Digest: digest},
+			want:      "ghcr.io/acme/site:v3",
+		},
+		{
+			name:      "given by digest",
+			reference: "ghcr.io/acme/site@" + digest,
+			status:    &webv1beta1.ImageSourceStatus{Reference: "ghcr.io/acme/site:v2", Digest: "sha256:other"},
+			want:      "ghcr.io/acme/site@" + digest,
+		},
+		{
+			name:      "registry port",
+			reference: "registry.local:5000/site:v1",
+			status:    &webv1beta1.ImageSourceStatus{Reference: "registry.local:5000/site:v1", Digest: digest},
+			want:      "registry.local:5000/site@" + digest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Source = &webv1beta1.SourceSpec{Image: &webv1beta1.ImageSource{Reference: tt.reference}}
+			if tt.status != nil {
+				site.Status.Source = &webv1beta1.SourceStatus{Image: tt.status}
+			}
+			if got := pinnedImage(site); got != tt.want {
+				t.Errorf("pinnedImage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// A storage migration copies the content to a new claim with a Job while the
+// current claim keeps serving, then points the Deployment at the new claim
+// and deletes the old one once no pod mounts it anymore.
+const (
+	migrationImage       = "alpine:3.20"
+	migrationSourceMount = "/source"
+	migrationTargetMount = "/target"
+)
+
+// Reasons of the Events emitted during a storage migration.
+const (
+	eventMigrationStarted   = "MigrationStarted"
+	eventMigrationSwitched  = "MigrationSwitched"
+	eventMigrationCompleted = "MigrationCompleted"
+	eventMigrationFailed    = "MigrationFailed"
+	eventMigrationCancelled = "MigrationCancelled"
+)
+
+// needsMigration reports whether the claim differs from spec.storage in a way
+// Kubernetes cannot apply in place: a smaller size, another storage class or
+// other access modes.
+func needsMigration(site *webv1beta1.NginxStaticSite, pvc *corev1.PersistentVolumeClaim, size resource.Quantity) bool {
+	if size.Cmp(pvc.Spec.Resources.Requests[corev1.ResourceStorage]) < 0 {
+		return true
+	}
+	if class := site.Spec.Storage.StorageClassName; class != nil && *class != ptr.Deref(pvc.Spec.StorageClassName, "") {
+		return true
+	}
+	if mode := site.Spec.Storage.VolumeMode; mode != nil &&
+		*mode != ptr.Deref(pvc.Spec.VolumeMode, corev1.PersistentVolumeFilesystem) {
+		return true
+	}
+	want := slices.Clone(pvcAccessModes(site))
+	have := slices.Clone(pvc.Spec.AccessModes)
+	slices.Sort(want)
+	slices.Sort(have)
+	return !slices.Equal(want, have)
+}
+
+// migrationClaimName names the claim migrated to after the storage settings,
+// so a change in the middle of a migration starts over with a new claim.
+func migrationClaimName(site *webv1beta1.NginxStaticSite, size resource.Quantity) string {
+	hash := configChecksum(fmt.Sprintf("%s\n%s\n%v",
+		size.String(), ptr.Deref(site.Spec.Storage.StorageClassName, ""), pvcAccessModes(site)))
+	return pvcName(site) + "-" + hash[:8]
+}
+
+func migrationJobName(site *webv1beta1.NginxStaticSite, target string) string {
+	return stackName(site) + "-migrate-" + configChecksum(target)[:10]
+}
+
+// reconcileMigration moves the content from the current claim to one
+// matching spec.storage. Once the copy Job succeeded, status.storage.claimName
+// points at the new claim, which the Deployment and finishMigration pick up.
+func (r *NginxStaticSiteReconciler) reconcileMigration(ctx context.Context, site *webv1beta1.NginxStaticSite,
+	source *corev1.PersistentVolumeClaim, size resource.Quantity) error {
+	if site.Status.Storage == nil {
+		site.Status.Storage = &webv1beta1.StorageStatus{}
+	}
+	status := site.Status.Storage
+	target := migrationClaimName(site, size)
+
+	migration := status.Migration
+	if migration == nil || migration.TargetClaimName != target {
+		if migration != nil {
+			if err := r.cancelMigration(ctx, site); err != nil {
+				return err
+			}
+		}
+		migration = &webv1beta1.StorageMigrationStatus{
+			SourceClaimName: source.Name,
+			TargetClaimName: target,
+			StartTime:       ptr.To(metav1.Now()),
+		}
+		status.Migration = migration
+		r.event(site, corev1.EventTypeNormal, eventMigrationStarted,
+			fmt.Sprintf("Migrating content from PVC %s to PVC %s", source.Name, target))
+	}
+
+	if err := r.apply(ctx, site, desiredPVC(site, target, size)); err != nil {
+		return err
+	}
+	job := desiredMigrationJob(site, source.Name, target)
+	if err := r.applyContentJob(ctx, site, job); err != nil {
+		return err
+	}
+
+	finishedAt, failed := jobFinished(job)
+	switch {
+	case finishedAt == nil:
+		migration.Phase = webv1beta1.MigrationCopying
+		migration.Message = fmt.Sprintf("Copying content from PVC %s to PVC %s", source.Name, target)
+		setCondition(site, webv1beta1.ConditionStorageMigrating, metav1.ConditionTrue, migration.Phase, migration.Message)
+	case failed:
+		if migration.Phase != webv1beta1.MigrationFailed {
+			migration.Phase = webv1beta1.MigrationFailed
+			migration.Message = r.jobOutput(ctx, job, "copy Job failed")
+			r.event(site, corev1.EventTypeWarning, eventMigrationFailed,
+				fmt.Sprintf("Copying content to PVC %s failed: %s", target, migration.Message))
+		}
+		setCondition(site, webv1beta1.ConditionStorageMigrating, metav1.ConditionFalse,
+			webv1beta1.ReasonMigrationFailed, migration.Message)
+	default:
+		status.ClaimName = target
+		migration.Phase = webv1beta1.MigrationSwitching
+		migration.Message = fmt.Sprintf("Moving the pods from PVC %s to PVC %s", source.Name, target)
+		setCondition(site, webv1beta1.ConditionStorageMigrating, metav1.ConditionTrue, migration.Phase, migration.Message)
+		r.event(site, corev1.EventTypeNormal, eventMigrationSwitched,
+			fmt.Sprintf("Copied content to PVC %s, moving the pods over", target))
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishMigration runs once the current claim matches spec.storage. It
+// deletes the old claim of a switched migration when no site pod mounts it
+// anymore, or cancels a migration the spec no longer asks for.
+func (r *NginxStaticSiteReconciler) finishMigration(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	status := site.Status.Storage
+	if status == nil || status.Migration == nil {
+		return nil
+	}
+	migration := status.Migration
+	if migration.Phase != webv1beta1.MigrationSwitching || status.ClaimName != migration.TargetClaimName {
+		return r.cancelMigration(ctx, site)
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(site.Namespace), client.MatchingLabels(selectorLabels(site))); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		for _, volume := range pods.Items[i].Spec.Volumes {
+			if claim := volume.PersistentVolumeClaim; claim != nil && claim.ClaimName == migration.SourceClaimName {
+				return nil
+			}
+		}
+	}
+
+	err := r.Delete(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: migration.SourceClaimName, Namespace: site.Namespace},
+	})
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	message := fmt.Sprintf("Migrated content from PVC %s to PVC %s", migration.SourceClaimName, migration.TargetClaimName)
+	setCondition(site, webv1beta1.ConditionStorageMigrating, metav1.ConditionFalse, webv1beta1.ReasonMigrationCompleted, message)
+	r.event(site, corev1.EventTypeNormal, eventMigrationCompleted, message)
+	status.Migration = nil
+	return nil
+}
+
+// cancelMigration drops an unfinished migration along with its claim and Job.
+func (r *NginxStaticSiteReconciler) cancelMigration(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	migration := site.Status.Storage.Migration
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name: migrationJobName(site, migration.TargetClaimName), Namespace: site.Namespace,
+	}}
+	err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	err = r.Delete(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: migration.TargetClaimName, Namespace: site.Namespace},
+	})
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	message := fmt.Sprintf("Cancelled the migration to PVC %s", migration.TargetClaimName)
+	setCondition(site, webv1beta1.ConditionStorageMigrating, metav1.ConditionFalse, webv1beta1.ReasonMigrationCancelled, message)
+	r.event(site, corev1.EventTypeNormal, eventMigrationCancelled, message)
+	site.Status.Storage.Migration = nil
+	return nil
+}
+
+// desiredMigrationJob builds the Job copying the content between the claims.
+// It runs next to the nginx pods, which hold the current claim.
+func desiredMigrationJob(site *webv1beta1.NginxStaticSite, source, target string) *batchv1.Job {
+	claimVolume := func(name, claim string) corev1.Volume {
+		return corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			},
+		}
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationJobName(site, target),
+			Namespace: site.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "copy",
+							Image:   migrationImage,
+							Command: []string{"cp", "-a", migrationSourceMount + "/.", migrationTargetMount},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "source", MountPath: migrationSourceMount, ReadOnly: true},
+								{Name: "target", MountPath: migrationTargetMount},
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+					Volumes: []corev1.Volume{claimVolume("source", source), claimVolume("target", target)},
+				},
+			},
+		},
+	}
+}
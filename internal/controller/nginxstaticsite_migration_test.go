@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+)
+
+func TestNeedsMigration(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         string
+		class        *string
+		accessModes  []corev1.PersistentVolumeAccessMode
+		claimClass   *string
+		claimModes   []corev1.PersistentVolumeAccessMode
+		volumeMode   *corev1.PersistentVolumeMode
+		claimVolume  *corev1.PersistentVolumeMode
+		wantMigrated bool
+	}{
+		{
+			name:       "unchanged",
+			size:       "1Gi",
+			claimModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+		{
+			name:       "expansion happens in place",
+			size:       "5Gi",
+			claimModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+		{
+			name:         "shrink",
+			size:         "512Mi",
+			claimModes:   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			wantMigrated: true,
+		},
+		{
+			name:       "class left to the cluster default",
+			size:       "1Gi",
+			claimClass: ptr.To("standard"),
+			claimModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+		{
+			name:         "other class",
+			size:         "1Gi",
+			class:        ptr.To("fast"),
+			claimClass:   ptr.To("standard"),
+			claimModes:   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			wantMigrated: true,
+		},
+		{
+			name:        "same access modes in another order",
+			size:        "1Gi",
+			accessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany, corev1.ReadWriteOnce},
+			claimModes:  []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteMany},
+		},
+		{
+			name:         "other access modes",
+			size:         "1Gi",
+			accessModes:  []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			claimModes:   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			wantMigrated: true,
+		},
+		{
+			name:       "filesystem volume mode on a defaulted claim",
+			size:       "1Gi",
+			claimModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			volumeMode: ptr.To(corev1.PersistentVolumeFilesystem),
+		},
+		{
+			name:         "other volume mode",
+			size:         "1Gi",
+			claimModes:   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			volumeMode:   ptr.To(corev1.PersistentVolumeFilesystem),
+			claimVolume:  ptr.To(corev1.PersistentVolumeBlock),
+			wantMigrated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Storage.StorageClassName = tt.class
+			site.Spec.Storage.AccessModes = tt.accessModes
+			site.Spec.Storage.VolumeMode = tt.volumeMode
+			pvc := testClaim("1Gi", "1Gi", corev1.ClaimBound)
+			pvc.Spec.StorageClassName = tt.claimClass
+			pvc.Spec.AccessModes = tt.claimModes
+			pvc.Spec.VolumeMode = tt.claimVolume
+			if got := needsMigration(site, pvc, resource.MustParse(tt.size)); got != tt.wantMigrated {
+				t.Errorf("needsMigration() = %v, want %v", got, tt.wantMigrated)
+			}
+		})
+	}
+}
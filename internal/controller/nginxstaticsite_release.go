@@ -0,0 +1,415 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// A release runs a second stack next to the live one, in the colour the site
+// is not served from: the blue stack is named after the site, the green one
+// "<name>-green". It is built from an in-memory copy of the site (see
+// stackSite). On promotion the site Service selects the release pods,
+// status.activeColor records the new colour, the release becomes the live
+// spec and the previous stack is deleted.
+const (
+	// promoteAnnotation on the site switches it over to a ready release.
+	promoteAnnotation = "web.ictplus.ir/promote"
+	// stackOfAnnotation marks an in-memory stack copy of a site with the name
+	// of the site owning its children. It is never written to the API server.
+	stackOfAnnotation = "web.ictplus.ir/stack-of"
+	// colorAnnotation marks an in-memory stack copy with the colour of the
+	// stack it builds. It is never written to the API server either.
+	colorAnnotation = "web.ictplus.ir/color"
+)
+
+// Reasons of the Events emitted for a release.
+const (
+	eventReleaseStaged    = "ReleaseStaged"
+	eventReleaseReady     = "ReleaseReady"
+	eventReleasePromoted  = "ReleasePromoted"
+	eventReleaseCompleted = "ReleaseCompleted"
+	eventReleaseAborted   = "ReleaseAborted"
+)
+
+// activeColor is the colour of the stack serving the site.
+func activeColor(site *webv1beta1.NginxStaticSite) string {
+	if site.Status.ActiveColor == webv1beta1.ColorGreen {
+		return webv1beta1.ColorGreen
+	}
+	return webv1beta1.ColorBlue
+}
+
+// idleColor is the colour of the stack a release is staged in.
+func idleColor(site *webv1beta1.NginxStaticSite) string {
+	if activeColor(site) == webv1beta1.ColorGreen {
+		return webv1beta1.ColorBlue
+	}
+	return webv1beta1.ColorGreen
+}
+
+// stackName prefixes the names and pod labels of the claim, config,
+// Deployment and Jobs serving a site: the site name for the blue stack and
+// "<name>-green" for the green one. Stack copies without a colour, such as
+// the canary, use their own name.
+func stackName(site *webv1beta1.NginxStaticSite) string {
+	name, color := site.Name, site.Status.ActiveColor
+	if parent := site.Annotations[stackOfAnnotation]; parent != "" {
+		color = site.Annotations[colorAnnotation]
+		if color == "" {
+			return site.Name
+		}
+		name = parent
+	}
+	if color == webv1beta1.ColorGreen {
+		return name + "-" + webv1beta1.ColorGreen
+	}
+	return name
+}
+
+// colorSite returns the site as seen by its stack of the given colour. The
+// Service, Ingress and HTTPRoute previewing that stack are named
+// "<name>-<colour>", apart from those of the site.
+func colorSite(site *webv1beta1.NginxStaticSite, color string) *webv1beta1.NginxStaticSite {
+	stack := stackSite(site, "-"+color)
+	stack.Annotations[colorAnnotation] = color
+	return stack
+}
+
+// releaseSite returns the site as seen by the stack its release is staged
+// in: spec.release applied over the live spec, a fixed replica count, the
+// preview routing and the release part of the status.
+func releaseSite(site *webv1beta1.NginxStaticSite) *webv1beta1.NginxStaticSite {
+	stack := colorSite(site, idleColor(site))
+	spec := &stack.Spec
+	replicas := desiredReplicas(site)
+	if as := site.Spec.Autoscaling; as != nil {
+		replicas = ptr.Deref(as.MinReplicas, 1)
+	}
+	spec.Replicas = ptr.To(replicas)
+	spec.Content.Revision = ""
+	if release := site.Spec.Release; release != nil {
+		if release.Source != nil {
+			spec.Source = release.Source.DeepCopy()
+		}
+		if release.ImageVersion != "" {
+			spec.Pod.ImageVersion = release.ImageVersion
+		}
+		if release.Preview.Host != "" {
+			spec.Routing.Host = release.Preview.Host
+		}
+		spec.Routing.Path = release.Preview.Path
+		if spec.Routing.Host != site.Spec.Routing.Host {
+			// The certificate of the site may not cover the preview host.
+			spec.TLS = nil
+		}
+	}
+	if status := site.Status.Release; status != nil {
+		stack.Status.Source = status.Source.DeepCopy()
+		stack.Status.Content = status.Content.DeepCopy()
+		stack.Status.ConfigChecksum = status.ConfigChecksum
+	}
+	return stack
+}
+
+// stackSite returns an in-memory copy of the site named "<name><suffix>",
+// for a second set of children next to the live ones. They are named and
+// labelled like those of a site of that name while being owned by the real
+// site. The copy runs a fixed number of replicas and no release or canary.
+func stackSite(site *webv1beta1.NginxStaticSite, suffix string) *webv1beta1.NginxStaticSite {
+	stack := &webv1beta1.NginxStaticSite{
+		TypeMeta: site.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      site.Name + suffix,
+			Namespace: site.Namespace,
+			UID:       site.UID,
+			Annotations: map[string]string{
+				stackOfAnnotation:       site.Name,
+				syncRequestedAnnotation: site.Annotations[syncRequestedAnnotation],
+			},
+		},
+		Spec: *site.Spec.DeepCopy(),
+	}
+	stack.Spec.Autoscaling = nil
+	stack.Spec.Release = nil
+	stack.Spec.Canary = nil
+	return stack
+}
+
+// owner returns the site owning the children built for site, which differs
+// for the stack copies of a site.
+func owner(site *webv1beta1.NginxStaticSite) *webv1beta1.NginxStaticSite {
+	parent := site.Annotations[stackOfAnnotation]
+	if parent == "" {
+		return site
+	}
+	return &webv1beta1.NginxStaticSite{
+		TypeMeta:   site.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{Name: parent, Namespace: site.Namespace, UID: site.UID},
+	}
+}
+
+// rolledOut reports whether every replica of the Deployment runs its current
+// template and is available.
+func rolledOut(deploy *appsv1.Deployment) bool {
+	want := ptr.Deref(deploy.Spec.Replicas, 1)
+	status := deploy.Status
+	return status.ObservedGeneration >= deploy.Generation && status.Replicas == want &&
+		status.UpdatedReplicas == want && status.AvailableReplicas == want
+}
+
+// contentFetched reports whether the Jobs filling the site volume are done
+// with the source in the spec. Other sources are ready along with the pods.
+func (r *NginxStaticSiteReconciler) contentFetched(ctx context.Context, site *webv1beta1.NginxStaticSite) (bool, error) {
+	if fetchesAtStartup(site) {
+		return true, nil
+	}
+	if archive := archiveSource(site); archive != nil {
+		status := ptr.Deref(site.Status.Source, webv1beta1.SourceStatus{})
+		return status.Archive != nil && status.Archive.SHA256 == archive.SHA256, nil
+	}
+	if s3 := s3Source(site); s3 != nil {
+		job := &batchv1.Job{}
+		err := r.Get(ctx, client.ObjectKey{Name: s3SyncJobName(site), Namespace: site.Namespace}, job)
+		if err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		finishedAt, failed := jobFinished(job)
+		return finishedAt != nil && !failed && job.Annotations[sourceHashAnnotation] == s3SourceHash(s3), nil
+	}
+	return true, nil
+}
+
+// reconcileRelease runs the stack of spec.release next to the live one and
+// walks it through its phases. It reports true when it updated the site spec
+// on promotion, ending this reconcile.
+func (r *NginxStaticSiteReconciler) reconcileRelease(ctx context.Context, site *webv1beta1.NginxStaticSite) (bool, error) {
+	status := site.Status.Release
+	if site.Spec.Release == nil {
+		if status == nil {
+			return false, nil
+		}
+		if status.Phase == webv1beta1.ReleasePromoted {
+			previous := colorSite(site, idleColor(site))
+			if err := r.retirePrevious(ctx, site, previous); err != nil {
+				return false, err
+			}
+			site.Status.Release = nil
+			r.event(site, corev1.EventTypeNormal, eventReleaseCompleted,
+				deploymentName(site)+" serves the release, deleted "+deploymentName(previous))
+			return false, nil
+		}
+		if err := r.retireStack(ctx, releaseSite(site)); err != nil {
+			return false, err
+		}
+		site.Status.Release = nil
+		r.event(site, corev1.EventTypeNormal, eventReleaseAborted, "Removed the unpromoted release")
+		return false, nil
+	}
+	if status == nil {
+		status = &webv1beta1.ReleaseStatus{Phase: webv1beta1.ReleaseStaging}
+		site.Status.Release = status
+		r.event(site, corev1.EventTypeNormal, eventReleaseStaged, "Staging the release in "+deploymentName(releaseSite(site)))
+	}
+
+	release := releaseSite(site)
+	deploy, err := r.reconcileReleaseStack(ctx, release)
+	status.Source = release.Status.Source
+	status.Content = release.Status.Content
+	status.ConfigChecksum = release.Status.ConfigChecksum
+	if err != nil {
+		return false, err
+	}
+	status.ReadyReplicas = deploy.Status.ReadyReplicas
+
+	fetched, err := r.contentFetched(ctx, release)
+	if err != nil {
+		return false, err
+	}
+	switch cond := meta.FindStatusCondition(release.Status.Conditions, webv1beta1.ConditionContentReady); {
+	case cond != nil && cond.Status == metav1.ConditionFalse:
+		status.Phase = webv1beta1.ReleaseStaging
+		status.Message = cond.Message
+		return false, nil
+	case !rolledOut(deploy) || !fetched:
+		status.Phase = webv1beta1.ReleaseStaging
+		status.Message = "Waiting for " + deploy.Name + " to roll out"
+		return false, nil
+	}
+	if status.Phase != webv1beta1.ReleaseReady {
+		r.event(site, corev1.EventTypeNormal, eventReleaseReady, deploy.Name+" is ready to be promoted")
+	}
+	status.Phase = webv1beta1.ReleaseReady
+	status.Message = "Annotate the site with " + promoteAnnotation + " to switch over to " + deploy.Name
+	if site.Annotations[promoteAnnotation] == "" {
+		return false, nil
+	}
+	return true, r.promoteRelease(ctx, site, deploy)
+}
+
+// promoteRelease makes the release stack the live one: the site takes over
+// its colour and status, the site Service selects its pods and the previous
+// stack is deleted. The release then becomes the live spec, which the release
+// stack already runs, so its pods and content are kept as they are.
+func (r *NginxStaticSiteReconciler) promoteRelease(ctx context.Context, site *webv1beta1.NginxStaticSite,
+	deploy *appsv1.Deployment) error {
+	status := site.Status.Release
+	previous := colorSite(site, activeColor(site))
+	previous.Status.Storage = site.Status.Storage.DeepCopy()
+
+	site.Status.ActiveColor = idleColor(site)
+	site.Status.Source = status.Source
+	site.Status.Content = status.Content
+	site.Status.ConfigChecksum = status.ConfigChecksum
+	// The release stack has a claim of its own, and no migration to finish.
+	site.Status.Storage = nil
+	status.Phase = webv1beta1.ReleasePromoted
+	status.PromoteTime = ptr.To(metav1.Now())
+	status.Message = "Deleting the previous stack " + deploymentName(previous)
+	if err := r.Status().Update(ctx, site); err != nil {
+		return err
+	}
+	if err := r.apply(ctx, site, desiredService(site)); err != nil {
+		return err
+	}
+	r.event(site, corev1.EventTypeNormal, eventReleasePromoted, serviceName(site)+" now serves "+deploy.Name)
+	if err := r.retirePrevious(ctx, site, previous); err != nil {
+		return err
+	}
+	return r.adoptRelease(ctx, site)
+}
+
+// adoptRelease makes spec.release the live spec and removes the promote
+// annotation. A pinned content revision belongs to the previous content and
+// is dropped.
+func (r *NginxStaticSiteReconciler) adoptRelease(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	release := site.Spec.Release
+	if release.Source != nil {
+		site.Spec.Source = release.Source
+	}
+	site.Spec.Content.Revision = ""
+	if release.ImageVersion != "" {
+		site.Spec.Pod.ImageVersion = release.ImageVersion
+	}
+	site.Spec.Release = nil
+	delete(site.Annotations, promoteAnnotation)
+	return r.Update(ctx, site)
+}
+
+// reconcileReleaseStack applies the stack of the release: its claim, config,
+// Deployment, content Jobs and Service, plus the preview Ingress or
+// HTTPRoute. It returns the release Deployment.
+func (r *NginxStaticSiteReconciler) reconcileReleaseStack(ctx context.Context, release *webv1beta1.NginxStaticSite) (*appsv1.Deployment, error) {
+	if usesPVC(release) {
+		pvc := desiredPVC(release, pvcName(release), resourceMustParse(release.Spec.Storage.Size))
+		if err := r.apply(ctx, release, pvc); err != nil {
+			return nil, err
+		}
+	}
+	config, err := r.reconcileConfig(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	contentChecksum, err := r.reconcileProjectedContent(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	deploy := desiredDeployment(release, configChecksum(config), contentChecksum)
+	if err := r.apply(ctx, release, deploy); err != nil {
+		return nil, err
+	}
+	if _, err := r.reconcileContent(ctx, release); err != nil {
+		return nil, err
+	}
+	if err := r.apply(ctx, release, desiredService(release)); err != nil {
+		return nil, err
+	}
+	if gatewayRouting(release) {
+		return deploy, r.apply(ctx, release, desiredHTTPRoute(release, 0))
+	}
+	return deploy, r.apply(ctx, release, desiredIngress(release))
+}
+
+// retirePrevious deletes the stack the site was served from before the
+// promotion, and the preview of the stack serving it now.
+func (r *NginxStaticSiteReconciler) retirePrevious(ctx context.Context, site, previous *webv1beta1.NginxStaticSite) error {
+	if err := r.retireStack(ctx, previous); err != nil {
+		return err
+	}
+	return r.deletePreview(ctx, colorSite(site, activeColor(site)))
+}
+
+// retireStack deletes a stack of the site: its Deployment, config, claim,
+// content Jobs and preview.
+func (r *NginxStaticSiteReconciler) retireStack(ctx context.Context, stack *webv1beta1.NginxStaticSite) error {
+	objs := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName(stack)}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMapName(stack)}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: s3SyncJobName(stack)}},
+	}
+	// A claim given as existingClaim is not the operator's.
+	if stack.Spec.Storage.ExistingClaim == "" {
+		objs = append(objs, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: contentClaimName(stack)}})
+	}
+	if err := r.deleteAll(ctx, stack, objs); err != nil {
+		return err
+	}
+	if err := r.deletePreview(ctx, stack); err != nil {
+		return err
+	}
+	if err := r.deleteSiteJobs(ctx, stack, archiveSiteLabel, ""); err != nil {
+		return err
+	}
+	return r.deleteSiteJobs(ctx, stack, revisionSiteLabel, "")
+}
+
+// deletePreview deletes the Service, Ingress and HTTPRoute exposing a stack
+// on its preview host or path.
+func (r *NginxStaticSiteReconciler) deletePreview(ctx context.Context, stack *webv1beta1.NginxStaticSite) error {
+	err := r.deleteAll(ctx, stack, []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName(stack)}},
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: ingressName(stack)}},
+	})
+	if err != nil || !gatewayRouting(stack) {
+		return err
+	}
+	return r.deleteHTTPRoute(ctx, stack)
+}
+
+// deleteAll deletes objs from the namespace of the stack, skipping those
+// already gone.
+func (r *NginxStaticSiteReconciler) deleteAll(ctx context.Context, stack *webv1beta1.NginxStaticSite, objs []client.Object) error {
+	for _, obj := range objs {
+		obj.SetNamespace(stack.Namespace)
+		err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
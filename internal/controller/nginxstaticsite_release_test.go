@@ -0,0 +1,98 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestStackNames(t *testing.T) {
+	green := testSite()
+	green.Status.ActiveColor = webv1beta1.ColorGreen
+	release := &webv1beta1.ReleaseSpec{ImageVersion: "1.27.1"}
+
+	tests := []struct {
+		name           string
+		site           func() *webv1beta1.NginxStaticSite
+		wantDeployment string
+		wantService    string
+		wantPath       string
+	}{
+		{
+			name:           "blue site",
+			site:           testSite,
+			wantDeployment: "docs-nginx",
+			wantService:    "docs-svc",
+			wantPath:       "/docs",
+		},
+		{
+			name:           "green site",
+			site:           func() *webv1beta1.NginxStaticSite { return green.DeepCopy() },
+			wantDeployment: "docs-green-nginx",
+			wantService:    "docs-svc",
+			wantPath:       "/docs",
+		},
+		{
+			name: "release of a blue site",
+			site: func() *webv1beta1.NginxStaticSite {
+				site := testSite()
+				site.Spec.Release = release
+				return releaseSite(site)
+			},
+			wantDeployment: "docs-green-nginx",
+			wantService:    "docs-green-svc",
+			wantPath:       "/docs-green",
+		},
+		{
+			name: "release of a green site",
+			site: func() *webv1beta1.NginxStaticSite {
+				site := green.DeepCopy()
+				site.Spec.Release = release
+				return releaseSite(site)
+			},
+			wantDeployment: "docs-nginx",
+			wantService:    "docs-blue-svc",
+			wantPath:       "/docs-blue",
+		},
+		{
+			name:           "canary of a green site",
+			site:           func() *webv1beta1.NginxStaticSite { return stackSite(green, canarySuffix) },
+			wantDeployment: "docs-canary-nginx",
+			wantService:    "docs-canary-svc",
+			wantPath:       "/docs-canary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := tt.site()
+			if got := deploymentName(site); got != tt.wantDeployment {
+				t.Errorf("deploymentName() = %q, want %q", got, tt.wantDeployment)
+			}
+			if got := selectorLabels(site)["app"]; got+"-nginx" != tt.wantDeployment {
+				t.Errorf("selectorLabels() app = %q, want the pods of %q", got, tt.wantDeployment)
+			}
+			if got := serviceName(site); got != tt.wantService {
+				t.Errorf("serviceName() = %q, want %q", got, tt.wantService)
+			}
+			if got := routingPath(site); got != tt.wantPath {
+				t.Errorf("routingPath() = %q, want %q", got, tt.wantPath)
+			}
+		})
+	}
+}
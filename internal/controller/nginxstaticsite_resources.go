@@ -0,0 +1,310 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// The builders below return only the fields the operator owns. They are
+// server-side applied, so anything they leave out (fields defaulted by the API
+// server or set by other controllers such as sidecar injectors) is not touched.
+
+// Names of the child resources created for a site.
+func pvcName(site *webv1beta1.NginxStaticSite) string        { return stackName(site) + "-pvc" }
+func deploymentName(site *webv1beta1.NginxStaticSite) string { return stackName(site) + "-nginx" }
+func serviceName(site *webv1beta1.NginxStaticSite) string    { return site.Name + "-svc" }
+func ingressName(site *webv1beta1.NginxStaticSite) string    { return site.Name + "-ing" }
+func hpaName(site *webv1beta1.NginxStaticSite) string        { return site.Name + "-hpa" }
+func configMapName(site *webv1beta1.NginxStaticSite) string  { return stackName(site) + "-config" }
+
+// requestsPerSecondMetric is the pods metric backing spec.autoscaling.targetRequestsPerSecond.
+const requestsPerSecondMetric = "nginx_http_requests_per_second"
+
+// selectorLabels are the labels selecting the nginx pods of a site, those of
+// the stack serving it.
+func selectorLabels(site *webv1beta1.NginxStaticSite) map[string]string {
+	return map[string]string{"app": stackName(site)}
+}
+
+// desiredPVC builds a claim named name holding the site content from spec.storage.
+func desiredPVC(site *webv1beta1.NginxStaticSite, name string, size resource.Quantity) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: site.Namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: site.Spec.Storage.StorageClassName,
+			AccessModes:      pvcAccessModes(site),
+			VolumeMode:       site.Spec.Storage.VolumeMode,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+}
+
+// pvcAccessModes returns the access modes of the site PVC, defaulting from
+// the storage mode.
+func pvcAccessModes(site *webv1beta1.NginxStaticSite) []corev1.PersistentVolumeAccessMode {
+	if modes := site.Spec.Storage.AccessModes; len(modes) > 0 {
+		return modes
+	}
+	if storageMode(site) == webv1beta1.StorageModeReadOnlyMany {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+	}
+	return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+}
+
+// desiredDeployment builds the nginx Deployment serving the site volume with
+// the rendered config, plus the git-sync containers of a git source, the copy
+// step of an image source or, in emptyDir mode, the fetch step of an S3 or
+// archive source. The config checksum, and the content checksum of a
+// configMapRef or secretRef source, roll the pods on change. Its replicas follow
+// spec.replicas; with spec.autoscaling set the caller hands them over to the
+// HPA (see autoscaledReplicas).
+func desiredDeployment(site *webv1beta1.NginxStaticSite, configChecksum, contentChecksum string) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName(site),
+			Namespace: site.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(desiredReplicas(site)),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorLabels(site),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: selectorLabels(site),
+					Annotations: map[string]string{
+						configChecksumAnnotation: configChecksum,
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector: site.Spec.Pod.NodeSelector,
+					Containers: []corev1.Container{
+						{
+							Name:      "nginx",
+							Image:     "nginx:" + site.Spec.Pod.ImageVersion,
+							Resources: ptr.Deref(site.Spec.Pod.Resources, corev1.ResourceRequirements{}),
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "static-content",
+									MountPath: site.Spec.Content.Path,
+									ReadOnly:  storageMode(site) == webv1beta1.StorageModeReadOnlyMany,
+								},
+								{
+									Name:      "nginx-config",
+									MountPath: configMountPath,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						contentVolume(site),
+						{
+							Name: "nginx-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(site)},
+									Items:                []corev1.KeyToPath{{Key: configFileName, Path: configFileName}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if contentChecksum != "" {
+		deploy.Spec.Template.Annotations[contentChecksumAnnotation] = contentChecksum
+	}
+	if gitSource(site) != nil {
+		podSpec := &deploy.Spec.Template.Spec
+		podSpec.SecurityContext = &corev1.PodSecurityContext{FSGroup: ptr.To[int64](gitSyncGroup)}
+		podSpec.InitContainers = append(podSpec.InitContainers, gitSyncContainer(site, "git-sync-init", true))
+		podSpec.Containers = append(podSpec.Containers, gitSyncContainer(site, "git-sync", false))
+		podSpec.Volumes = append(podSpec.Volumes, gitSyncVolumes(site)...)
+	}
+	if image := imageSource(site); image != nil {
+		podSpec := &deploy.Spec.Template.Spec
+		podSpec.ImagePullSecrets = image.PullSecrets
+		if !imageVolumeMount(image) {
+			podSpec.InitContainers = append(podSpec.InitContainers, imageCopyInitContainer(site))
+		}
+	}
+	if fetchesAtStartup(site) {
+		podSpec := &deploy.Spec.Template.Spec
+		switch {
+		case s3Source(site) != nil:
+			podSpec.InitContainers = append(podSpec.InitContainers, s3SyncContainer(site, "s3-fetch"))
+		case archiveSource(site) != nil:
+			podSpec.InitContainers = append(podSpec.InitContainers, archiveExtractContainer(site, "archive-fetch"))
+		}
+		// Every replica fetches on its own, so a sync request rolls them all.
+		if request := site.Annotations[syncRequestedAnnotation]; request != "" {
+			deploy.Spec.Template.Annotations[syncRequestAnnotation] = request
+		}
+	}
+	return deploy
+}
+
+// desiredHPA builds the autoscaler for the nginx Deployment from spec.autoscaling.
+func desiredHPA(site *webv1beta1.NginxStaticSite) *autoscalingv2.HorizontalPodAutoscaler {
+	as := site.Spec.Autoscaling
+
+	var metrics []autoscalingv2.MetricSpec
+	if as.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: corev1.ResourceCPU,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: as.TargetCPUUtilizationPercentage,
+				},
+			},
+		})
+	}
+	if as.TargetRequestsPerSecond != nil {
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: requestsPerSecondMetric},
+				Target: autoscalingv2.MetricTarget{
+					Type:         autoscalingv2.AverageValueMetricType,
+					AverageValue: as.TargetRequestsPerSecond,
+				},
+			},
+		})
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hpaName(site),
+			Namespace: site.Namespace,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       deploymentName(site),
+			},
+			MinReplicas: as.MinReplicas,
+			MaxReplicas: as.MaxReplicas,
+			Metrics:     metrics,
+		},
+	}
+}
+
+// desiredService builds the ClusterIP Service in front of the nginx pods of
+// the stack serving the site.
+func desiredService(site *webv1beta1.NginxStaticSite) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName(site),
+			Namespace: site.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: selectorLabels(site),
+			Ports: []corev1.ServicePort{
+				{
+					Port:       80,
+					Protocol:   corev1.ProtocolTCP,
+					TargetPort: intstr.FromInt(80),
+				},
+			},
+			Type: corev1.ServiceTypeClusterIP,
+		},
+	}
+}
+
+// routingPath is the URL prefix the site is served under.
+func routingPath(site *webv1beta1.NginxStaticSite) string {
+	if site.Spec.Routing.Path == "" {
+		return "/" + site.Name
+	}
+	return site.Spec.Routing.Path
+}
+
+// desiredIngress builds the Ingress from spec.routing and spec.tls, pointing
+// at the site Service.
+func desiredIngress(site *webv1beta1.NginxStaticSite) *networkingv1.Ingress {
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ingressName(site),
+			Namespace: site.Namespace,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: site.Spec.Routing.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: site.Spec.Routing.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     routingPath(site),
+									PathType: ptr.To(networkingv1.PathTypePrefix),
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: serviceName(site),
+											Port: networkingv1.ServiceBackendPort{
+												Number: 80,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if site.Spec.TLS != nil {
+		tls := networkingv1.IngressTLS{SecretName: site.Spec.TLS.SecretName}
+		if site.Spec.Routing.Host != "" {
+			tls.Hosts = []string{site.Spec.Routing.Host}
+		}
+		ing.Spec.TLS = []networkingv1.IngressTLS{tls}
+	}
+	return ing
+}
+
+// desiredReplicas returns the requested replica count, falling back to the
+// Deployment default of 1 when the defaulting webhook did not run.
+func desiredReplicas(site *webv1beta1.NginxStaticSite) int32 {
+	if site.Spec.Replicas == nil {
+		return 1
+	}
+	return *site.Spec.Replicas
+}
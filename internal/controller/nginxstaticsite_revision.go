@@ -0,0 +1,254 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// Revisioned content lives in complete copies under revisions/ on the site
+// volume. The current symlink points at the one being served and is swapped
+// with a rename, so nginx never sees a half-written site.
+const (
+	revisionsDir                = "revisions"
+	defaultRevisionHistoryLimit = 5
+	revisionImage               = "alpine:3.20"
+	// revisionSiteLabel marks the Jobs switching the served revision of a site.
+	revisionSiteLabel = "web.ictplus.ir/revision-of"
+)
+
+// revisionFunctions are the shell helpers of the Jobs writing revisions:
+// publish serves revisions/$1, prune keeps the newest $KEEP revisions besides
+// the one being served, and report prints the revisions as JSON for the operator.
+const revisionFunctions = `
+publish() {
+  ln -sfn "` + revisionsDir + `/$1" ` + contentJobMountPath + `/.current.tmp
+  mv -Tf ` + contentJobMountPath + `/.current.tmp ` + contentJobMountPath + "/" + currentLink + `
+}
+served() {
+  link=$(readlink ` + contentJobMountPath + "/" + currentLink + ` 2>/dev/null || true)
+  echo "${link##*/}"
+}
+prune() {
+  keep=$(served)
+  ls -1t ` + contentJobMountPath + "/" + revisionsDir + ` | grep -vxF "$keep" | tail -n +$((KEEP + 1)) | while read -r rev; do
+    rm -rf "` + contentJobMountPath + "/" + revisionsDir + `/$rev"
+  done
+}
+report() {
+  printf '{"revision":"%s","history":[' "$(served)"
+  sep=
+  for rev in $(ls -1t ` + contentJobMountPath + "/" + revisionsDir + `); do
+    printf '%s{"name":"%s","time":%s}' "$sep" "$rev" "$(stat -c %Y "` + contentJobMountPath + "/" + revisionsDir + `/$rev")"
+    sep=,
+  done
+  printf ']}\n'
+}
+`
+
+// revisionSwitchScript publishes an existing revision.
+const revisionSwitchScript = `set -eu
+` + revisionFunctions + `
+if [ ! -d "` + contentJobMountPath + "/" + revisionsDir + `/$REVISION" ]; then
+  echo "revision $REVISION not found under ` + revisionsDir + `/" >&2
+  exit 1
+fi
+publish "$REVISION"
+prune
+report > /dev/termination-log
+`
+
+// revisioned reports whether the content is kept in revisions: S3 and archive
+// sources write one per change, and without a source spec.content.revision
+// publishes uploaded ones.
+func revisioned(site *webv1beta1.NginxStaticSite) bool {
+	if site.Spec.Source == nil {
+		return site.Spec.Content.Revision != ""
+	}
+	return s3Source(site) != nil || archiveSource(site) != nil
+}
+
+// revisionHistoryLimit returns how many revisions are kept besides the served one.
+func revisionHistoryLimit(site *webv1beta1.NginxStaticSite) int32 {
+	return ptr.Deref(site.Spec.Content.RevisionHistoryLimit, defaultRevisionHistoryLimit)
+}
+
+// revisionEnv is the environment of the revision shell helpers. New revisions
+// are only published while no revision is pinned.
+func revisionEnv(site *webv1beta1.NginxStaticSite) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "KEEP", Value: strconv.Itoa(int(revisionHistoryLimit(site)))},
+		{Name: "PUBLISH", Value: strconv.FormatBool(site.Spec.Content.Revision == "")},
+	}
+}
+
+// revisionReport is what the report helper prints.
+type revisionReport struct {
+	Revision string `json:"revision"`
+	History  []struct {
+		Name string `json:"name"`
+		Time int64  `json:"time"`
+	} `json:"history"`
+}
+
+// updateContentStatus records the revisions reported on the last line of a
+// Job's termination message.
+func updateContentStatus(site *webv1beta1.NginxStaticSite, output string) error {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	var report revisionReport
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &report); err != nil {
+		return fmt.Errorf("parsing revision report: %w", err)
+	}
+	status := &webv1beta1.ContentStatus{Revision: report.Revision}
+	for _, rev := range report.History {
+		status.History = append(status.History, webv1beta1.ContentRevision{
+			Name:         rev.Name,
+			CreationTime: metav1.NewTime(time.Unix(rev.Time, 0)),
+		})
+	}
+	site.Status.Content = status
+	return nil
+}
+
+// updateJobContentStatus records the revisions reported by a fetch Job that
+// finished at finishedAt, unless seen says the Job was handled already: they
+// are only taken once, as a rollback may have changed them since.
+func updateJobContentStatus(site *webv1beta1.NginxStaticSite, seen, finishedAt *metav1.Time, output string) error {
+	if seen.Equal(finishedAt) {
+		return nil
+	}
+	return updateContentStatus(site, output)
+}
+
+// reconcileRevision serves spec.content.revision, or the newest revision
+// again once the pin is removed, with a switch Job. The fetch Jobs publish
+// new revisions themselves, so nothing runs while the right one is served.
+func (r *NginxStaticSiteReconciler) reconcileRevision(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if !revisioned(site) || fetchesAtStartup(site) {
+		site.Status.Content = nil
+		return r.deleteSiteJobs(ctx, site, revisionSiteLabel, "")
+	}
+
+	want := site.Spec.Content.Revision
+	status := site.Status.Content
+	if want == "" {
+		if status == nil || len(status.History) == 0 || status.Revision == status.History[0].Name {
+			return r.deleteSiteJobs(ctx, site, revisionSiteLabel, "")
+		}
+		want = status.History[0].Name
+	} else if status != nil && status.Revision == want {
+		// A failed fetch still shows, though the pinned revision is served.
+		if ready := meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionContentReady); ready == nil ||
+			ready.Status == metav1.ConditionTrue {
+			setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionTrue, webv1beta1.ReasonRevisionPublished,
+				"Serving revision "+want)
+		}
+		return r.deleteSiteJobs(ctx, site, revisionSiteLabel, "")
+	}
+
+	job := desiredRevisionJob(site, want)
+	if err := r.applyContentJob(ctx, site, job); err != nil {
+		return err
+	}
+	if err := r.deleteSiteJobs(ctx, site, revisionSiteLabel, job.Name); err != nil {
+		return err
+	}
+
+	finishedAt, failed := jobFinished(job)
+	switch {
+	case finishedAt == nil:
+		// The previous revision keeps being served meanwhile.
+	case failed:
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonRevisionFailed,
+			r.jobOutput(ctx, job, "revision switch Job failed"))
+	default:
+		if err := updateContentStatus(site, r.jobOutput(ctx, job, "")); err != nil {
+			return err
+		}
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionTrue, webv1beta1.ReasonRevisionPublished,
+			"Serving revision "+want)
+	}
+	return nil
+}
+
+// deleteSiteJobs removes the Jobs of the site carrying label, except keep.
+func (r *NginxStaticSiteReconciler) deleteSiteJobs(ctx context.Context, site *webv1beta1.NginxStaticSite, label, keep string) error {
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(site.Namespace),
+		client.MatchingLabels{label: stackName(site)}); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if jobs.Items[i].Name == keep {
+			continue
+		}
+		err := r.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desiredRevisionJob builds the Job publishing revision. It is named after
+// the revision and the sync request annotation, so a failed switch can be
+// retried on demand.
+func desiredRevisionJob(site *webv1beta1.NginxStaticSite, revision string) *batchv1.Job {
+	hash := configChecksum(revision + "\n" + site.Annotations[syncRequestedAnnotation])
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stackName(site) + "-revision-" + hash[:10],
+			Namespace: site.Namespace,
+			Labels:    map[string]string{revisionSiteLabel: stackName(site)},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](1),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "switch",
+							Image:   revisionImage,
+							Command: []string{"sh", "-c", revisionSwitchScript},
+							Env:     append(revisionEnv(site), corev1.EnvVar{Name: "REVISION", Value: revision}),
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "static-content", MountPath: contentJobMountPath},
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+					Volumes: []corev1.Volume{contentVolume(site)},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestUpdateJobContentStatus(t *testing.T) {
+	finishedAt := metav1.NewTime(time.Unix(1700000000, 0))
+	earlier := metav1.NewTime(finishedAt.Add(-time.Hour))
+	output := `{"revision":"archive-0123456789ab","history":[{"name":"archive-0123456789ab","time":1700000000}]}`
+
+	tests := []struct {
+		name string
+		seen *metav1.Time
+		want string
+	}{
+		{name: "first Job", want: "archive-0123456789ab"},
+		{name: "new Job", seen: &earlier, want: "archive-0123456789ab"},
+		{name: "Job already handled", seen: &finishedAt, want: "rolled-back"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Status.Content = &webv1beta1.ContentStatus{Revision: "rolled-back"}
+			if err := updateJobContentStatus(site, tt.seen, &finishedAt, output); err != nil {
+				t.Fatalf("updateJobContentStatus() error = %v", err)
+			}
+			if got := site.Status.Content.Revision; got != tt.want {
+				t.Errorf("revision = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
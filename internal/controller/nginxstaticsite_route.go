@@ -0,0 +1,216 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// httpRouteGVK is the Gateway API HTTPRoute kind. It is handled as
+// unstructured so the operator does not depend on the Gateway API client,
+// and runs on clusters without its CRDs as long as no site uses it.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+func httpRouteName(site *webv1beta1.NginxStaticSite) string { return site.Name + "-route" }
+
+// routePollInterval is how often a site re-reads its HTTPRoute status when
+// the Gateway API CRDs were installed after the operator started, so the
+// HTTPRoutes are not watched.
+const routePollInterval = time.Minute
+
+// gatewayRouting reports whether the site is exposed through an HTTPRoute
+// instead of an Ingress.
+func gatewayRouting(site *webv1beta1.NginxStaticSite) bool {
+	return site.Spec.Routing.Type == webv1beta1.RoutingTypeGatewayAPI
+}
+
+// canaryWeight is the percentage of requests the site route sends to the
+// canary Service.
+func canaryWeight(site *webv1beta1.NginxStaticSite) int32 {
+	if status := site.Status.Canary; status != nil && status.Phase == webv1beta1.CanaryProgressing {
+		return status.Weight
+	}
+	return 0
+}
+
+// desiredHTTPRoute builds the HTTPRoute attaching the site to its Gateway,
+// matching the host and path prefix the Ingress would. canaryWeight percent
+// of the requests go to the canary Service. Without a Gateway the route is
+// attached to nothing.
+func desiredHTTPRoute(site *webv1beta1.NginxStaticSite, canaryWeight int32) *unstructured.Unstructured {
+	parentRefs := []any{}
+	if gateway := site.Spec.Routing.Gateway; gateway != nil {
+		parentRef := map[string]any{
+			"group": httpRouteGVK.Group,
+			"kind":  "Gateway",
+			"name":  gateway.Name,
+		}
+		if gateway.Namespace != "" {
+			parentRef["namespace"] = gateway.Namespace
+		}
+		if gateway.SectionName != "" {
+			parentRef["sectionName"] = gateway.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+
+	backendRefs := []any{backendRef(serviceName(site), 100-canaryWeight)}
+	if canaryWeight > 0 {
+		backendRefs = append(backendRefs, backendRef(serviceName(stackSite(site, canarySuffix)), canaryWeight))
+	}
+	spec := map[string]any{
+		"parentRefs": parentRefs,
+		"rules": []any{map[string]any{
+			"matches": []any{map[string]any{
+				"path": map[string]any{"type": "PathPrefix", "value": routingPath(site)},
+			}},
+			"backendRefs": backendRefs,
+		}},
+	}
+	if host := site.Spec.Routing.Host; host != "" {
+		spec["hostnames"] = []any{host}
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(httpRouteName(site))
+	route.SetNamespace(site.Namespace)
+	return route
+}
+
+// backendRef is an HTTPRoute backend sending weight to port 80 of a Service.
+func backendRef(service string, weight int32) map[string]any {
+	return map[string]any{
+		"name":   service,
+		"port":   int64(80),
+		"weight": int64(weight),
+	}
+}
+
+// reconcileHTTPRoute applies the site HTTPRoute, deletes the Ingress of a
+// site switched over from ingress routing and reports the route status.
+func (r *NginxStaticSiteReconciler) reconcileHTTPRoute(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionIngressReady) != nil {
+		err := r.Delete(ctx, &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: ingressName(site), Namespace: site.Namespace},
+		})
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionIngressReady)
+	}
+	route := desiredHTTPRoute(site, canaryWeight(site))
+	if err := r.apply(ctx, site, route); err != nil {
+		return err
+	}
+	return setRouteConditions(site, route)
+}
+
+// removeHTTPRoute deletes the HTTPRoute of a site switched over to ingress
+// routing. Sites that never had one are recognised by their conditions, so
+// clusters without the Gateway API CRDs are left alone.
+func (r *NginxStaticSiteReconciler) removeHTTPRoute(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	if meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionRouteAccepted) == nil {
+		return nil
+	}
+	if err := r.deleteHTTPRoute(ctx, site); err != nil {
+		return err
+	}
+	meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionRouteAccepted)
+	meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionRouteResolvedRefs)
+	return nil
+}
+
+// deleteHTTPRoute deletes the HTTPRoute of site, if any.
+func (r *NginxStaticSiteReconciler) deleteHTTPRoute(ctx context.Context, site *webv1beta1.NginxStaticSite) error {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(httpRouteName(site))
+	route.SetNamespace(site.Namespace)
+	if err := r.Delete(ctx, route); client.IgnoreNotFound(err) != nil && !meta.IsNoMatchError(err) {
+		return err
+	}
+	return nil
+}
+
+// setRouteConditions copies the Accepted and ResolvedRefs conditions the
+// Gateway reports on the route into RouteAccepted and RouteResolvedRefs.
+// Both are Unknown until the Gateway controller picks the route up.
+func setRouteConditions(site *webv1beta1.NginxStaticSite, route *unstructured.Unstructured) error {
+	var status struct {
+		Parents []struct {
+			ParentRef struct {
+				Name        string `json:"name"`
+				Namespace   string `json:"namespace,omitempty"`
+				SectionName string `json:"sectionName,omitempty"`
+			} `json:"parentRef"`
+			Conditions []metav1.Condition `json:"conditions,omitempty"`
+		} `json:"parents,omitempty"`
+	}
+	if raw, found, _ := unstructured.NestedMap(route.Object, "status"); found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &status); err != nil {
+			return err
+		}
+	}
+
+	gateway := site.Spec.Routing.Gateway
+	if gateway == nil {
+		for _, siteType := range []string{webv1beta1.ConditionRouteAccepted, webv1beta1.ConditionRouteResolvedRefs} {
+			setCondition(site, siteType, metav1.ConditionFalse, webv1beta1.ReasonGatewayMissing,
+				"spec.routing.gateway is required for gatewayAPI routing")
+		}
+		return nil
+	}
+	namespace := gateway.Namespace
+	if namespace == "" {
+		namespace = site.Namespace
+	}
+	pending := "Waiting for Gateway " + namespace + "/" + gateway.Name + " to report on HTTPRoute " + route.GetName()
+	conditions := map[string]string{
+		"Accepted":     webv1beta1.ConditionRouteAccepted,
+		"ResolvedRefs": webv1beta1.ConditionRouteResolvedRefs,
+	}
+	for routeType, siteType := range conditions {
+		setCondition(site, siteType, metav1.ConditionUnknown, webv1beta1.ReasonRoutePending, pending)
+		for _, parent := range status.Parents {
+			ref := parent.ParentRef
+			if ref.Name != gateway.Name || (ref.Namespace != "" && ref.Namespace != namespace) ||
+				ref.SectionName != gateway.SectionName {
+				continue
+			}
+			if cond := meta.FindStatusCondition(parent.Conditions, routeType); cond != nil {
+				reason := cond.Reason
+				if reason == "" {
+					reason = webv1beta1.ReasonRoutePending
+				}
+				setCondition(site, siteType, cond.Status, reason, cond.Message)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func TestSetRouteConditions(t *testing.T) {
+	parent := func(name, namespace, section string, status metav1.ConditionStatus) any {
+		ref := map[string]any{"name": name}
+		if namespace != "" {
+			ref["namespace"] = namespace
+		}
+		if section != "" {
+			ref["sectionName"] = section
+		}
+		return map[string]any{
+			"parentRef": ref,
+			"conditions": []any{
+				map[string]any{"type": "Accepted", "status": string(status), "reason": "Accepted", "message": "accepted",
+					"lastTransitionTime": "2025-01-01T00:00:00Z"},
+				map[string]any{"type": "ResolvedRefs", "status": string(status), "reason": "ResolvedRefs",
+					"lastTransitionTime": "2025-01-01T00:00:00Z"},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		section string
+		parents []any
+		want    metav1.ConditionStatus
+	}{
+		{
+			name: "no status yet",
+			want: metav1.ConditionUnknown,
+		},
+		{
+			name:    "accepted by the gateway",
+			parents: []any{parent("web", "", "", metav1.ConditionTrue)},
+			want:    metav1.ConditionTrue,
+		},
+		{
+			name:    "rejected by the gateway",
+			parents: []any{parent("web", "default", "", metav1.ConditionFalse)},
+			want:    metav1.ConditionFalse,
+		},
+		{
+			name:    "reported by another gateway",
+			parents: []any{parent("other", "", "", metav1.ConditionTrue)},
+			want:    metav1.ConditionUnknown,
+		},
+		{
+			name:    "reported in another namespace",
+			parents: []any{parent("web", "infra", "", metav1.ConditionTrue)},
+			want:    metav1.ConditionUnknown,
+		},
+		{
+			name:    "reported for another listener",
+			section: "https",
+			parents: []any{parent("web", "", "http", metav1.ConditionTrue)},
+			want:    metav1.ConditionUnknown,
+		},
+		{
+			name:    "reported for the listener",
+			section: "https",
+			parents: []any{parent("web", "", "https", metav1.ConditionTrue)},
+			want:    metav1.ConditionTrue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			site.Spec.Routing.Type = webv1beta1.RoutingTypeGatewayAPI
+			site.Spec.Routing.Gateway = &webv1beta1.GatewayReference{Name: "web", SectionName: tt.section}
+			route := desiredHTTPRoute(site, 0)
+			if tt.parents != nil {
+				route.Object["status"] = map[string]any{"parents": tt.parents}
+			}
+			if err := setRouteConditions(site, route); err != nil {
+				t.Fatalf("setRouteConditions() error = %v", err)
+			}
+			for _, condType := range []string{webv1beta1.ConditionRouteAccepted, webv1beta1.ConditionRouteResolvedRefs} {
+				cond := meta.FindStatusCondition(site.Status.Conditions, condType)
+				if cond == nil || cond.Status != tt.want {
+					t.Errorf("%s = %+v, want status %s", condType, cond, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPRouteWithoutGateway(t *testing.T) {
+	site := testSite()
+	site.Spec.Routing.Type = webv1beta1.RoutingTypeGatewayAPI
+	route := desiredHTTPRoute(site, 0)
+	if refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs"); len(refs) != 0 {
+		t.Errorf("parentRefs = %v, want none", refs)
+	}
+	if err := setRouteConditions(site, route); err != nil {
+		t.Fatalf("setRouteConditions() error = %v", err)
+	}
+	for _, condType := range []string{webv1beta1.ConditionRouteAccepted, webv1beta1.ConditionRouteResolvedRefs} {
+		cond := meta.FindStatusCondition(site.Status.Conditions, condType)
+		if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != webv1beta1.ReasonGatewayMissing {
+			t.Errorf("%s = %+v, want False with reason %s", condType, cond, webv1beta1.ReasonGatewayMissing)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+const (
+	rcloneImage = "rclone/rclone:1.68"
+	// syncRequestedAnnotation on the site triggers a sync whenever its value changes.
+	syncRequestedAnnotation = "web.ictplus.ir/sync-requested-at"
+	// Annotations on the sync Job recording what it synced, so the operator
+	// can tell when the next sync is due.
+	sourceHashAnnotation  = "web.ictplus.ir/source-hash"
+	syncRequestAnnotation = "web.ictplus.ir/sync-request"
+)
+
+// s3SyncScript mirrors the bucket into a new revision, seeded with hard links
+// to the newest one so unchanged objects are neither downloaded nor stored
+// twice. A sync that changed nothing is dropped. The termination message holds
+// the ` + "`rclone size`" + ` totals followed by the revision report.
+const s3SyncScript = `set -eu
+` + revisionFunctions + `
+revisions=` + contentJobMountPath + "/" + revisionsDir + `
+mkdir -p "$revisions"
+latest=$(ls -1t "$revisions" | head -n 1)
+new="$revisions/.sync"
+rm -rf "$new"
+if [ -n "$latest" ]; then
+  cp -al "$revisions/$latest" "$new"
+else
+  mkdir "$new"
+fi
+rclone sync ":s3:${S3_PATH}" "$new" --s3-provider=Other --s3-env-auth --s3-endpoint="${S3_ENDPOINT}" --s3-region="${S3_REGION}"
+size=$(rclone size "$new" --json)
+if [ -n "$latest" ] && diff -rq "$revisions/$latest" "$new" > /dev/null; then
+  rm -rf "$new"
+  rev=$latest
+else
+  rev=s3-$(date -u +%Y%m%d-%H%M%S)
+  mv "$new" "$revisions/$rev"
+fi
+if [ "$PUBLISH" = true ]; then
+  publish "$rev"
+fi
+prune
+{ echo "$size"; report; } > /dev/termination-log
+`
+
+func s3SyncJobName(site *webv1beta1.NginxStaticSite) string { return stackName(site) + "-s3-sync" }
+
+// reconcileS3 keeps a sync Job running whenever the bucket is due to be synced
+// and records the outcome of the last one. It returns when the next periodic
+// sync is due, or zero when none is scheduled.
+func (r *NginxStaticSiteReconciler) reconcileS3(ctx context.Context, site *webv1beta1.NginxStaticSite) (time.Duration, error) {
+	key := client.ObjectKey{Name: s3SyncJobName(site), Namespace: site.Namespace}
+	s3 := s3Source(site)
+	if s3 == nil || fetchesAtStartup(site) {
+		if site.Status.Source != nil {
+			site.Status.Source.S3 = nil
+		}
+		return 0, client.IgnoreNotFound(r.Delete(ctx, &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		}, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+	}
+	if site.Status.Source == nil {
+		site.Status.Source = &webv1beta1.SourceStatus{}
+	}
+	if site.Status.Source.S3 == nil {
+		site.Status.Source.S3 = &webv1beta1.S3SourceStatus{}
+	}
+	status := site.Status.Source.S3
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, key, job)
+	if errors.IsNotFound(err) {
+		if meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionContentReady) == nil {
+			setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonSyncing,
+				"Syncing s3://"+s3.Bucket+"/"+s3.Prefix)
+		}
+		return 0, r.applyContentJob(ctx, site, desiredS3SyncJob(site))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	finishedAt, failed := jobFinished(job)
+	if finishedAt == nil {
+		return 0, nil
+	}
+	if failed {
+		status.LastError = r.jobOutput(ctx, job, "sync Job failed")
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionFalse, webv1beta1.ReasonSyncFailed, status.LastError)
+	} else {
+		var size struct {
+			Count int64 `json:"count"`
+			Bytes int64 `json:"bytes"`
+		}
+		output := r.jobOutput(ctx, job, "{}")
+		if err := json.Unmarshal([]byte(strings.SplitN(output, "\n", 2)[0]), &size); err != nil {
+			return 0, fmt.Errorf("parsing sync Job output: %w", err)
+		}
+		if err := updateJobContentStatus(site, status.LastSyncTime, finishedAt, output); err != nil {
+			return 0, err
+		}
+		status.ObjectCount = size.Count
+		status.Bytes = size.Bytes
+		status.LastSyncTime = finishedAt
+		status.LastError = ""
+		setCondition(site, webv1beta1.ConditionContentReady, metav1.ConditionTrue, webv1beta1.ReasonSynced,
+			fmt.Sprintf("Synced %d objects (%d bytes) from s3://%s/%s", size.Count, size.Bytes, s3.Bucket, s3.Prefix))
+	}
+
+	// Replace the finished Job once the source or the sync request changed,
+	// or the sync interval elapsed.
+	next := time.Duration(0)
+	due := job.Annotations[sourceHashAnnotation] != s3SourceHash(s3) ||
+		job.Annotations[syncRequestAnnotation] != site.Annotations[syncRequestedAnnotation]
+	if s3.SyncInterval != nil {
+		next = time.Until(finishedAt.Add(s3.SyncInterval.Duration))
+		due = due || next <= 0
+	}
+	if !due {
+		return next, nil
+	}
+	return 0, client.IgnoreNotFound(r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+// s3Source returns spec.source.s3, or nil when the site is not synced from S3.
+func s3Source(site *webv1beta1.NginxStaticSite) *webv1beta1.S3Source {
+	if site.Spec.Source == nil {
+		return nil
+	}
+	return site.Spec.Source.S3
+}
+
+// s3SourceHash identifies the bucket location a Job synced.
+func s3SourceHash(s3 *webv1beta1.S3Source) string {
+	return configChecksum(s3.Endpoint + "\n" + s3.Region + "\n" + s3.Bucket + "\n" + s3.Prefix)[:16]
+}
+
+// desiredS3SyncJob builds the Job mirroring the bucket into the site volume.
+func desiredS3SyncJob(site *webv1beta1.NginxStaticSite) *batchv1.Job {
+	s3 := site.Spec.Source.S3
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s3SyncJobName(site),
+			Namespace: site.Namespace,
+			Annotations: map[string]string{
+				sourceHashAnnotation:  s3SourceHash(s3),
+				syncRequestAnnotation: site.Annotations[syncRequestedAnnotation],
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](1),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{s3SyncContainer(site, "rclone")},
+					Volumes:       []corev1.Volume{contentVolume(site)},
+				},
+			},
+		},
+	}
+}
+
+// s3SyncContainer builds the rclone container mirroring the bucket into the
+// site volume, run by the sync Job or, in emptyDir mode, as an init container.
+func s3SyncContainer(site *webv1beta1.NginxStaticSite, name string) corev1.Container {
+	s3 := site.Spec.Source.S3
+	container := corev1.Container{
+		Name:    name,
+		Image:   rcloneImage,
+		Command: []string{"sh", "-c", s3SyncScript},
+		Env: append(revisionEnv(site), []corev1.EnvVar{
+			{Name: "S3_PATH", Value: s3.Bucket + "/" + s3.Prefix},
+			{Name: "S3_ENDPOINT", Value: s3.Endpoint},
+			{Name: "S3_REGION", Value: s3.Region},
+		}...),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "static-content", MountPath: contentJobMountPath},
+		},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	if ref := s3.CredentialsSecretRef; ref != nil {
+		container.EnvFrom = []corev1.EnvFromSource{
+			{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: *ref}},
+		}
+	}
+
+	return container
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// setCondition records a condition against the site's current generation.
+func setCondition(site *webv1beta1.NginxStaticSite, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&site.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: site.Generation,
+	})
+}
+
+// markFailed records a failed reconcile step on the given condition, flags the
+// site as Degraded and not Ready, and persists the status. Errors writing the
+// status are only logged so the caller can return the original error.
+func (r *NginxStaticSiteReconciler) markFailed(ctx context.Context, site *webv1beta1.NginxStaticSite, condType, reason string, err error) {
+	setCondition(site, condType, metav1.ConditionFalse, reason, err.Error())
+	setCondition(site, webv1beta1.ConditionDegraded, metav1.ConditionTrue, reason, err.Error())
+	setCondition(site, webv1beta1.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+	site.Status.ObservedGeneration = site.Generation
+	if uerr := r.Status().Update(ctx, site); uerr != nil {
+		log.FromContext(ctx).Error(uerr, "failed to update status", "name", site.Name)
+	}
+}
+
+// setDeploymentCondition mirrors the Deployment's Available condition onto the site.
+func setDeploymentCondition(site *webv1beta1.NginxStaticSite, deploy *appsv1.Deployment) {
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type != appsv1.DeploymentAvailable {
+			continue
+		}
+		if cond.Status == corev1.ConditionTrue {
+			setCondition(site, webv1beta1.ConditionDeploymentAvailable, metav1.ConditionTrue,
+				webv1beta1.ReasonDeploymentAvailable, cond.Message)
+			return
+		}
+		setCondition(site, webv1beta1.ConditionDeploymentAvailable, metav1.ConditionFalse,
+			webv1beta1.ReasonDeploymentProgressing, cond.Message)
+		return
+	}
+	setCondition(site, webv1beta1.ConditionDeploymentAvailable, metav1.ConditionFalse,
+		webv1beta1.ReasonDeploymentProgressing, "Waiting for Deployment "+deploy.Name+" to become available")
+}
+
+// setIngressCondition reports the Ingress as reconciled, including its address once assigned.
+func setIngressCondition(site *webv1beta1.NginxStaticSite, ing *networkingv1.Ingress) {
+	message := "Ingress " + ing.Name + " is reconciled"
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			message += ", address " + lb.IP
+			break
+		}
+		if lb.Hostname != "" {
+			message += ", address " + lb.Hostname
+			break
+		}
+	}
+	setCondition(site, webv1beta1.ConditionIngressReady, metav1.ConditionTrue, webv1beta1.ReasonReconciled, message)
+}
+
+// setReadyCondition derives the Ready condition from the per-resource conditions
+// and the ready pod count against the expected replicas, and reports whether
+// the site is ready.
+func setReadyCondition(site *webv1beta1.NginxStaticSite, readyCount, replicas int32) bool {
+	for _, condType := range []string{
+		webv1beta1.ConditionStorageReady,
+		webv1beta1.ConditionDeploymentAvailable,
+		webv1beta1.ConditionServiceReady,
+		webv1beta1.ConditionIngressReady,
+		webv1beta1.ConditionRouteAccepted,
+		webv1beta1.ConditionRouteResolvedRefs,
+		webv1beta1.ConditionContentReady,
+	} {
+		cond := meta.FindStatusCondition(site.Status.Conditions, condType)
+		if cond != nil && cond.Status != metav1.ConditionTrue {
+			setCondition(site, webv1beta1.ConditionReady, metav1.ConditionFalse, cond.Reason, cond.Message)
+			return false
+		}
+	}
+	if readyCount < replicas {
+		setCondition(site, webv1beta1.ConditionReady, metav1.ConditionFalse, webv1beta1.ReasonPodsNotReady,
+			fmt.Sprintf("%d/%d replicas ready", readyCount, replicas))
+		return false
+	}
+	setCondition(site, webv1beta1.ConditionReady, metav1.ConditionTrue, webv1beta1.ReasonReconciled,
+		fmt.Sprintf("%d/%d replicas ready", readyCount, replicas))
+	return true
+}
+
+// setStorageCondition reports whether the site PVC is bound.
+func setStorageCondition(site *webv1beta1.NginxStaticSite, pvc *corev1.PersistentVolumeClaim) {
+	if pvc.Status.Phase == corev1.ClaimBound {
+		setCondition(site, webv1beta1.ConditionStorageReady, metav1.ConditionTrue, webv1beta1.ReasonPVCBound,
+			"PVC "+pvc.Name+" is bound")
+		return
+	}
+	setCondition(site, webv1beta1.ConditionStorageReady, metav1.ConditionFalse, webv1beta1.ReasonPVCPending,
+		"Waiting for PVC "+pvc.Name+" to be bound")
+}
+
+// setResizeCondition reports the expansion of the site PVC from its current
+// capacity to the requested size, following the Resizing and
+// FileSystemResizePending conditions of the claim. It is dropped while the
+// claim is not bound.
+func setResizeCondition(site *webv1beta1.NginxStaticSite, pvc *corev1.PersistentVolumeClaim) {
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if pvc.Status.Phase != corev1.ClaimBound || !ok {
+		meta.RemoveStatusCondition(&site.Status.Conditions, webv1beta1.ConditionStorageResizing)
+		return
+	}
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if capacity.Cmp(requested) >= 0 {
+		setCondition(site, webv1beta1.ConditionStorageResizing, metav1.ConditionFalse, webv1beta1.ReasonResized,
+			fmt.Sprintf("Capacity %s of PVC %s matches the requested %s", capacity.String(), pvc.Name, requested.String()))
+		return
+	}
+
+	reason := webv1beta1.ReasonResizeRequested
+	message := fmt.Sprintf("Expanding PVC %s from %s to %s", pvc.Name, capacity.String(), requested.String())
+	for _, cond := range pvc.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case corev1.PersistentVolumeClaimResizing:
+			reason = webv1beta1.ReasonResizing
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			reason = webv1beta1.ReasonFileSystemResizePending
+			message += "; the volume is expanded, the file system is resized when a node mounts it"
+		default:
+			continue
+		}
+		if cond.Message != "" {
+			message += ": " + cond.Message
+		}
+	}
+	setCondition(site, webv1beta1.ConditionStorageResizing, metav1.ConditionTrue, reason, message)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func testClaim(requested, capacity string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "docs-pvc"}}
+	pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(requested)}
+	pvc.Status.Phase = phase
+	if capacity != "" {
+		pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)}
+	}
+	return pvc
+}
+
+func TestSetResizeCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		pvc        *corev1.PersistentVolumeClaim
+		conditions []corev1.PersistentVolumeClaimCondition
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name: "pending claim",
+			pvc:  testClaim("1Gi", "", corev1.ClaimPending),
+		},
+		{
+			name:       "capacity matches",
+			pvc:        testClaim("1Gi", "1Gi", corev1.ClaimBound),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: webv1beta1.ReasonResized,
+		},
+		{
+			name:       "expansion requested",
+			pvc:        testClaim("2Gi", "1Gi", corev1.ClaimBound),
+			wantStatus: metav1.ConditionTrue,
+			wantReason: webv1beta1.ReasonResizeRequested,
+		},
+		{
+			name: "volume resizing",
+			pvc:  testClaim("2Gi", "1Gi", corev1.ClaimBound),
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				{Type: corev1.PersistentVolumeClaimResizing, Status: corev1.ConditionTrue},
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: webv1beta1.ReasonResizing,
+		},
+		{
+			name: "file system resize pending",
+			pvc:  testClaim("2Gi", "1Gi", corev1.ClaimBound),
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: webv1beta1.ReasonFileSystemResizePending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := testSite()
+			// A stale condition is replaced or dropped.
+			setCondition(site, webv1beta1.ConditionStorageResizing, metav1.ConditionTrue, webv1beta1.ReasonResizing, "")
+			tt.pvc.Status.Conditions = tt.conditions
+			setResizeCondition(site, tt.pvc)
+
+			cond := meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionStorageResizing)
+			if tt.wantStatus == "" {
+				if cond != nil {
+					t.Errorf("condition = %+v, want none", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %+v, want status %s reason %s", cond, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// volumeSnapshotGVK is the CSI VolumeSnapshot kind. It is handled as
+// unstructured so the operator does not depend on the snapshot client.
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+
+// Reasons of the Events emitted when a site PVC is finalized.
+const (
+	eventPVCDeleted       = "PVCDeleted"
+	eventPVCRetained      = "PVCRetained"
+	eventSnapshotCreated  = "SnapshotCreated"
+	eventSnapshotReady    = "SnapshotReady"
+	eventSnapshotFailed   = "SnapshotFailed"
+	eventPVCFinalizeError = "PVCFinalizeFailed"
+)
+
+// snapshotName names the VolumeSnapshot taken of the site PVC on deletion,
+// unique per site incarnation so a recreated site does not reuse it.
+func snapshotName(site *webv1beta1.NginxStaticSite) string {
+	return pvcName(site) + "-" + string(site.UID)[:8]
+}
+
+// finalizePVC applies spec.storage.deletionPolicy to the site PVC of a
+// deleted site and reports whether the finalizer can be removed. A claim
+// given as existingClaim is not the operator's and is left alone.
+func (r *NginxStaticSiteReconciler) finalizePVC(ctx context.Context, site *webv1beta1.NginxStaticSite) (bool, error) {
+	if site.Spec.Storage.ExistingClaim != "" {
+		return true, nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Name: contentClaimName(site), Namespace: site.Namespace}, pvc)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch site.Spec.Storage.DeletionPolicy {
+	case webv1beta1.DeletionPolicyRetain:
+		// Without the owner reference the garbage collector leaves the claim be.
+		refs := slices.DeleteFunc(slices.Clone(pvc.OwnerReferences), func(ref metav1.OwnerReference) bool {
+			return ref.UID == site.UID
+		})
+		if len(refs) != len(pvc.OwnerReferences) {
+			patch := client.MergeFrom(pvc.DeepCopy())
+			pvc.OwnerReferences = refs
+			if err := r.Patch(ctx, pvc, patch); err != nil {
+				r.event(site, corev1.EventTypeWarning, eventPVCFinalizeError, "Failed to retain PVC "+pvc.Name+": "+err.Error())
+				return false, err
+			}
+			r.event(site, corev1.EventTypeNormal, eventPVCRetained, "Retained PVC "+pvc.Name)
+		}
+		return true, nil
+	case webv1beta1.DeletionPolicySnapshot:
+		ready, err := r.snapshotPVC(ctx, site, pvc)
+		if err != nil || !ready {
+			return false, err
+		}
+	}
+
+	if err := r.Delete(ctx, pvc); client.IgnoreNotFound(err) != nil {
+		r.event(site, corev1.EventTypeWarning, eventPVCFinalizeError, "Failed to delete PVC "+pvc.Name+": "+err.Error())
+		return false, err
+	}
+	r.event(site, corev1.EventTypeNormal, eventPVCDeleted, "Deleted PVC "+pvc.Name)
+	return true, nil
+}
+
+// snapshotPVC takes a VolumeSnapshot of the site PVC and reports whether it
+// is ready to use. The snapshot is not owned by the site, so it outlives it.
+func (r *NginxStaticSiteReconciler) snapshotPVC(ctx context.Context, site *webv1beta1.NginxStaticSite, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(volumeSnapshotGVK)
+	err := r.Get(ctx, client.ObjectKey{Name: snapshotName(site), Namespace: site.Namespace}, snap)
+	if meta.IsNoMatchError(err) {
+		return false, r.snapshotFailed(ctx, site, "VolumeSnapshot CRDs are not installed: "+err.Error())
+	}
+	if errors.IsNotFound(err) {
+		snap.SetName(snapshotName(site))
+		snap.SetNamespace(site.Namespace)
+		snap.SetLabels(map[string]string{"app": site.Name})
+		if err := unstructured.SetNestedField(snap.Object, pvc.Name, "spec", "source", "persistentVolumeClaimName"); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, snap); err != nil {
+			return false, r.snapshotFailed(ctx, site, "Failed to create VolumeSnapshot of PVC "+pvc.Name+": "+err.Error())
+		}
+		r.event(site, corev1.EventTypeNormal, eventSnapshotCreated,
+			"Created VolumeSnapshot "+snap.GetName()+" of PVC "+pvc.Name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if msg, found, _ := unstructured.NestedString(snap.Object, "status", "error", "message"); found && msg != "" {
+		return false, r.snapshotFailed(ctx, site, "VolumeSnapshot "+snap.GetName()+" failed: "+msg)
+	}
+	if ready, _, _ := unstructured.NestedBool(snap.Object, "status", "readyToUse"); !ready {
+		return false, nil
+	}
+	r.event(site, corev1.EventTypeNormal, eventSnapshotReady, "VolumeSnapshot "+snap.GetName()+" is ready to use")
+	return true, nil
+}
+
+// snapshotFailed reports a snapshot that cannot be taken in StorageReady.
+// The deletion is retried every few seconds, so the SnapshotFailed Event is
+// only emitted when the failure changes.
+func (r *NginxStaticSiteReconciler) snapshotFailed(ctx context.Context, site *webv1beta1.NginxStaticSite, msg string) error {
+	cond := meta.FindStatusCondition(site.Status.Conditions, webv1beta1.ConditionStorageReady)
+	if cond != nil && cond.Reason == webv1beta1.ReasonSnapshotFailed && cond.Message == msg {
+		return nil
+	}
+	r.event(site, corev1.EventTypeWarning, eventSnapshotFailed, msg)
+	setCondition(site, webv1beta1.ConditionStorageReady, metav1.ConditionFalse, webv1beta1.ReasonSnapshotFailed, msg)
+	return r.Status().Update(ctx, site)
+}
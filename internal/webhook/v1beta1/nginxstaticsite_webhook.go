@@ -0,0 +1,878 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var nginxstaticsitelog = logf.Log.WithName("nginxstaticsite-resource")
+
+// imageTagPattern matches a valid OCI image tag, see
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+var imageTagPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// Patterns for values rendered into the nginx config. Arguments must not be
+// able to end the directive or open a block.
+var (
+	nginxArgPattern    = regexp.MustCompile(`^[^\s;{}"'\\]+$`)
+	expiresPattern     = regexp.MustCompile(`^(off|max|epoch|-?[0-9]+(ms|s|m|h|d|w|M|y)?)$`)
+	headerNamePattern  = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+	mimeTypePattern    = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9!#$&^_.+-]*/([a-zA-Z0-9][a-zA-Z0-9!#$&^_.+-]*|\*)$`)
+	controlCharPattern = regexp.MustCompile(`[\x00-\x1f\x7f]`)
+	bucketPattern      = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	sha256Pattern      = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	revisionPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+)
+
+// maxRevisionHistoryLimit keeps the revision report within the 4096 bytes of
+// a termination message.
+const maxRevisionHistoryLimit = 20
+
+const nginxArgMessage = "must not contain whitespace, quotes, ';', '{' or '}'"
+
+// NginxStaticSiteDefaults holds the values the defaulting webhook fills in for
+// spec fields omitted from a NginxStaticSite.
+type NginxStaticSiteDefaults struct {
+	Replicas       int32
+	StorageSize    string
+	ImageVersion   string
+	StaticFilePath string
+}
+
+// Validate checks that the defaults would themselves pass validation, so a
+// misconfigured operator fails at startup instead of on every admission request.
+func (d NginxStaticSiteDefaults) Validate() error {
+	spec := webv1beta1.NginxStaticSiteSpec{
+		Replicas: &d.Replicas,
+		Content:  webv1beta1.ContentSpec{Path: d.StaticFilePath},
+		Storage:  webv1beta1.StorageSpec{Size: d.StorageSize},
+		Pod:      webv1beta1.PodSpec{ImageVersion: d.ImageVersion},
+	}
+	return validateSpec(&spec, field.NewPath("defaults")).ToAggregate()
+}
+
+// SetupNginxStaticSiteWebhookWithManager registers the webhook for NginxStaticSite in the manager.
+func SetupNginxStaticSiteWebhookWithManager(mgr ctrl.Manager, defaults NginxStaticSiteDefaults) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&webv1beta1.NginxStaticSite{}).
+		WithValidator(&NginxStaticSiteCustomValidator{}).
+		WithDefaulter(&NginxStaticSiteCustomDefaulter{Defaults: defaults}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-web-ictplus-ir-v1beta1-nginxstaticsite,mutating=true,failurePolicy=fail,sideEffects=None,groups=web.ictplus.ir,resources=nginxstaticsites,verbs=create;update,versions=v1beta1,name=mnginxstaticsite-v1beta1.kb.io,admissionReviewVersions=v1
+
+// NginxStaticSiteCustomDefaulter fills in omitted spec fields so minimal
+// manifests are accepted and the stored object shows the effective values.
+type NginxStaticSiteCustomDefaulter struct {
+	Defaults NginxStaticSiteDefaults
+}
+
+var _ webhook.CustomDefaulter = &NginxStaticSiteCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind NginxStaticSite.
+func (d *NginxStaticSiteCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	site, ok := obj.(*webv1beta1.NginxStaticSite)
+	if !ok {
+		return fmt.Errorf("expected an NginxStaticSite object but got %T", obj)
+	}
+	nginxstaticsitelog.Info("Defaulting for NginxStaticSite", "name", site.GetName())
+
+	if site.Spec.Replicas == nil {
+		site.Spec.Replicas = ptr.To(d.Defaults.Replicas)
+	}
+	if site.Spec.Storage.Size == "" {
+		site.Spec.Storage.Size = d.Defaults.StorageSize
+	}
+	if site.Spec.Pod.ImageVersion == "" {
+		site.Spec.Pod.ImageVersion = d.Defaults.ImageVersion
+	}
+	if site.Spec.Content.Path == "" {
+		site.Spec.Content.Path = d.Defaults.StaticFilePath
+	}
+
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-web-ictplus-ir-v1beta1-nginxstaticsite,mutating=false,failurePolicy=fail,sideEffects=None,groups=web.ictplus.ir,resources=nginxstaticsites,verbs=create;update,versions=v1beta1,name=vnginxstaticsite-v1beta1.kb.io,admissionReviewVersions=v1
+
+// NginxStaticSiteCustomValidator rejects NginxStaticSite specs that would
+// otherwise produce broken child resources.
+type NginxStaticSiteCustomValidator struct{}
+
+var _ webhook.CustomValidator = &NginxStaticSiteCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type NginxStaticSite.
+func (v *NginxStaticSiteCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	site, ok := obj.(*webv1beta1.NginxStaticSite)
+	if !ok {
+		return nil, fmt.Errorf("expected a NginxStaticSite object but got %T", obj)
+	}
+	nginxstaticsitelog.Info("Validation for NginxStaticSite upon creation", "name", site.GetName())
+
+	return specWarnings(&site.Spec), invalid(site, validateSpec(&site.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type NginxStaticSite.
+func (v *NginxStaticSiteCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	site, ok := newObj.(*webv1beta1.NginxStaticSite)
+	if !ok {
+		return nil, fmt.Errorf("expected a NginxStaticSite object for the newObj but got %T", newObj)
+	}
+	oldSite, ok := oldObj.(*webv1beta1.NginxStaticSite)
+	if !ok {
+		return nil, fmt.Errorf("expected a NginxStaticSite object for the oldObj but got %T", oldObj)
+	}
+	nginxstaticsitelog.Info("Validation for NginxStaticSite upon update", "name", site.GetName())
+
+	// Sites stored before a check existed must stay deletable, and the
+	// operator must be able to add and remove its finalizer on them.
+	if site.DeletionTimestamp != nil || reflect.DeepEqual(oldSite.Spec, site.Spec) {
+		return nil, nil
+	}
+
+	specPath := field.NewPath("spec")
+	allErrs := changedErrors(validateSpec(&site.Spec, specPath), validateSpec(&oldSite.Spec, specPath))
+	// With migration allowed, the operator moves the content to a new claim instead.
+	if !site.Spec.Storage.AllowMigration {
+		allErrs = append(allErrs, validateStorageResize(&oldSite.Spec, &site.Spec, specPath.Child("storage", "size"))...)
+		allErrs = append(allErrs, validateStorageUpdate(&oldSite.Spec.Storage, &site.Spec.Storage, specPath.Child("storage"))...)
+	}
+	// The stack a promoted release replaced is still being deleted.
+	if status := oldSite.Status.Release; status != nil && status.Phase == webv1beta1.ReleasePromoted &&
+		oldSite.Spec.Release == nil && site.Spec.Release != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("release"),
+			"cannot stage a release until the promoted one has completed"))
+	}
+
+	return specWarnings(&site.Spec), invalid(site, allErrs)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type NginxStaticSite.
+func (v *NginxStaticSiteCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// changedErrors drops the errors the stored spec already had, so an update
+// is only rejected for the fields it makes invalid.
+func changedErrors(allErrs, oldErrs field.ErrorList) field.ErrorList {
+	var errs field.ErrorList
+	for _, err := range allErrs {
+		if !slices.ContainsFunc(oldErrs, func(old *field.Error) bool {
+			return old.Type == err.Type && old.Field == err.Field && reflect.DeepEqual(old.BadValue, err.BadValue)
+		}) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateSpec checks every field of the spec in isolation.
+func validateSpec(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+
+	if spec.HistoryLimit != nil && *spec.HistoryLimit < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("historyLimit"), *spec.HistoryLimit, "must be greater than or equal to 1"))
+	}
+
+	if spec.Autoscaling != nil {
+		allErrs = append(allErrs, validateAutoscaling(spec.Autoscaling, fldPath.Child("autoscaling"))...)
+	}
+
+	sizePath := fldPath.Child("storage", "size")
+	if size, err := resource.ParseQuantity(spec.Storage.Size); err != nil {
+		allErrs = append(allErrs, field.Invalid(sizePath, spec.Storage.Size, err.Error()))
+	} else if size.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(sizePath, spec.Storage.Size, "must be greater than 0"))
+	}
+
+	contentPath := fldPath.Child("content", "path")
+	if !path.IsAbs(spec.Content.Path) {
+		allErrs = append(allErrs, field.Invalid(contentPath, spec.Content.Path, "must be an absolute path"))
+	} else if path.Clean(spec.Content.Path) == "/" {
+		allErrs = append(allErrs, field.Invalid(contentPath, spec.Content.Path, "must not be the root directory"))
+	}
+
+	allErrs = append(allErrs, validateRevision(spec, fldPath.Child("content"))...)
+	allErrs = append(allErrs, validateStorage(spec, fldPath.Child("storage"))...)
+
+	if spec.Source != nil {
+		allErrs = append(allErrs, validateSource(spec.Source, fldPath.Child("source"))...)
+		allErrs = append(allErrs, validateGitReplicas(spec, fldPath)...)
+	}
+	if spec.Release != nil {
+		allErrs = append(allErrs, validateRelease(spec, fldPath.Child("release"))...)
+	}
+	if spec.Canary != nil {
+		allErrs = append(allErrs, validateCanary(spec, fldPath.Child("canary"))...)
+	}
+	allErrs = append(allErrs, validateServer(&spec.Server, fldPath.Child("server"))...)
+	allErrs = append(allErrs, validateRouting(&spec.Routing, fldPath.Child("routing"))...)
+
+	podPath := fldPath.Child("pod")
+	if !imageTagPattern.MatchString(spec.Pod.ImageVersion) {
+		allErrs = append(allErrs, field.Invalid(podPath.Child("imageVersion"), spec.Pod.ImageVersion,
+			"must be a valid image tag matching "+imageTagPattern.String()))
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabels(spec.Pod.NodeSelector, podPath.Child("nodeSelector"))...)
+
+	if spec.TLS != nil && spec.TLS.SecretName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("tls", "secretName"), ""))
+	}
+
+	return allErrs
+}
+
+// validateGitReplicas limits a git source on a shared claim to a single
+// replica, as every pod runs its own git-sync and they would race on the same
+// repository and current link.
+func validateGitReplicas(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	mode := spec.Storage.Mode
+	if spec.Source.Git == nil || (mode != "" && mode != webv1beta1.StorageModePVC) || maxReplicas(spec) <= 1 {
+		return nil
+	}
+	const msg = "a git source on the site PVC supports a single replica; use spec.storage.mode emptyDir to run more"
+	if spec.Autoscaling != nil {
+		return field.ErrorList{field.Invalid(fldPath.Child("autoscaling", "maxReplicas"), spec.Autoscaling.MaxReplicas, msg)}
+	}
+	return field.ErrorList{field.Invalid(fldPath.Child("replicas"), *spec.Replicas, msg)}
+}
+
+// validateAutoscaling checks the replica bounds and targets of the site HPA.
+func validateAutoscaling(as *webv1beta1.AutoscalingSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if as.MaxReplicas < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxReplicas"), as.MaxReplicas, "must be greater than or equal to 1"))
+	}
+	if as.MinReplicas != nil {
+		if *as.MinReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minReplicas"), *as.MinReplicas, "must be greater than or equal to 1"))
+		} else if *as.MinReplicas > as.MaxReplicas {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minReplicas"), *as.MinReplicas, "must not be greater than maxReplicas"))
+		}
+	}
+	if as.TargetCPUUtilizationPercentage != nil && *as.TargetCPUUtilizationPercentage < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("targetCPUUtilizationPercentage"),
+			*as.TargetCPUUtilizationPercentage, "must be greater than 0"))
+	}
+	if as.TargetRequestsPerSecond != nil && as.TargetRequestsPerSecond.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("targetRequestsPerSecond"),
+			as.TargetRequestsPerSecond.String(), "must be greater than 0"))
+	}
+
+	return allErrs
+}
+
+// specWarnings flags valid specs that are unlikely to behave as intended.
+func specWarnings(spec *webv1beta1.NginxStaticSiteSpec) admission.Warnings {
+	var warnings admission.Warnings
+
+	if as := spec.Autoscaling; as != nil {
+		cpuTarget := as.TargetCPUUtilizationPercentage != nil || as.TargetRequestsPerSecond == nil
+		if cpuTarget && (spec.Pod.Resources == nil || spec.Pod.Resources.Requests.Cpu().IsZero()) {
+			warnings = append(warnings,
+				"spec.autoscaling scales on CPU utilization, which needs spec.pod.resources.requests.cpu to be set")
+		}
+	}
+
+	if spec.TLS != nil && spec.Routing.Type == webv1beta1.RoutingTypeGatewayAPI {
+		warnings = append(warnings,
+			"spec.tls is ignored with gatewayAPI routing, where TLS is terminated by the Gateway listener")
+	}
+
+	if maxReplicas(spec) > 1 && sharesReadWriteOnceClaim(spec) {
+		warnings = append(warnings,
+			"more than one replica on a ReadWriteOnce volume only works while all pods run on the same node; "+
+				"use spec.storage.accessModes [ReadWriteMany] or spec.storage.mode emptyDir to spread them")
+	}
+	if spec.Storage.Mode == webv1beta1.StorageModeEmptyDir && spec.Source != nil &&
+		spec.Source.S3 != nil && spec.Source.S3.SyncInterval != nil {
+		warnings = append(warnings,
+			"spec.source.s3.syncInterval is ignored in emptyDir mode, where every replica syncs once at startup")
+	}
+
+	return warnings
+}
+
+// maxReplicas is the most replicas the site may run.
+func maxReplicas(spec *webv1beta1.NginxStaticSiteSpec) int32 {
+	if spec.Autoscaling != nil {
+		return spec.Autoscaling.MaxReplicas
+	}
+	return ptr.Deref(spec.Replicas, 1)
+}
+
+// sharesReadWriteOnceClaim reports whether the replicas share a claim the
+// operator creates with a single-node access mode.
+func sharesReadWriteOnceClaim(spec *webv1beta1.NginxStaticSiteSpec) bool {
+	if spec.Storage.ExistingClaim != "" || (spec.Storage.Mode != "" && spec.Storage.Mode != webv1beta1.StorageModePVC) {
+		return false
+	}
+	if source := spec.Source; source != nil && (source.Image != nil || source.ConfigMapRef != nil || source.SecretRef != nil) {
+		return false
+	}
+	modes := spec.Storage.AccessModes
+	return len(modes) == 0 || !slices.ContainsFunc(modes, func(mode corev1.PersistentVolumeAccessMode) bool {
+		return mode == corev1.ReadWriteMany || mode == corev1.ReadOnlyMany
+	})
+}
+
+// validateStorage checks the site volume settings. The storage mode has to
+// fit the content source: an emptyDir starts out empty, and a ReadOnlyMany
+// claim cannot be written to, so it has to be provisioned with its content.
+func validateStorage(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	storage := &spec.Storage
+	source := spec.Source
+
+	modePath := fldPath.Child("mode")
+	switch storage.Mode {
+	case "", webv1beta1.StorageModePVC:
+	case webv1beta1.StorageModeEmptyDir:
+		if source == nil {
+			allErrs = append(allErrs, field.Invalid(modePath, storage.Mode, "requires spec.source to fetch the content from"))
+		}
+	case webv1beta1.StorageModeReadOnlyMany:
+		if source != nil && (source.Git != nil || source.S3 != nil || source.Archive != nil) {
+			allErrs = append(allErrs, field.Invalid(modePath, storage.Mode,
+				"cannot be combined with a git, s3 or archive source, which write to the volume"))
+		}
+		if storage.ExistingClaim == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("existingClaim"),
+				"readOnlyMany mode serves a claim provisioned outside the operator"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(modePath, storage.Mode, []string{
+			webv1beta1.StorageModePVC, webv1beta1.StorageModeEmptyDir, webv1beta1.StorageModeReadOnlyMany,
+		}))
+	}
+
+	switch storage.DeletionPolicy {
+	case "", webv1beta1.DeletionPolicyDelete, webv1beta1.DeletionPolicyRetain, webv1beta1.DeletionPolicySnapshot:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("deletionPolicy"), storage.DeletionPolicy, []string{
+			webv1beta1.DeletionPolicyDelete, webv1beta1.DeletionPolicyRetain, webv1beta1.DeletionPolicySnapshot,
+		}))
+	}
+
+	if class := storage.StorageClassName; class != nil && *class != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(*class) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("storageClassName"), *class, msg))
+		}
+	}
+	supportedModes := []corev1.PersistentVolumeAccessMode{
+		corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany, corev1.ReadWriteOncePod,
+	}
+	for i, mode := range storage.AccessModes {
+		if !slices.Contains(supportedModes, mode) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("accessModes").Index(i), mode, supportedModes))
+		}
+	}
+	if mode := storage.VolumeMode; mode != nil && *mode != corev1.PersistentVolumeFilesystem {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("volumeMode"), *mode,
+			[]corev1.PersistentVolumeMode{corev1.PersistentVolumeFilesystem}))
+	}
+
+	if storage.ExistingClaim != "" {
+		claimPath := fldPath.Child("existingClaim")
+		for _, msg := range validation.IsDNS1123Subdomain(storage.ExistingClaim) {
+			allErrs = append(allErrs, field.Invalid(claimPath, storage.ExistingClaim, msg))
+		}
+		if storage.StorageClassName != nil || len(storage.AccessModes) > 0 || storage.VolumeMode != nil {
+			allErrs = append(allErrs, field.Forbidden(claimPath,
+				"storageClassName, accessModes and volumeMode only apply to the claim created by the operator"))
+		}
+		if storage.Mode == webv1beta1.StorageModeEmptyDir {
+			allErrs = append(allErrs, field.Forbidden(claimPath, "cannot be used in emptyDir mode"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRevision checks spec.content.revision and the history limit.
+// Revisions are only kept on a writable claim, by the s3 and archive sources
+// or by whoever uploads them when there is no source.
+func validateRevision(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	content := &spec.Content
+
+	if limit := content.RevisionHistoryLimit; limit != nil && (*limit < 1 || *limit > maxRevisionHistoryLimit) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("revisionHistoryLimit"), *limit,
+			fmt.Sprintf("must be between 1 and %d", maxRevisionHistoryLimit)))
+	}
+
+	if content.Revision == "" {
+		return allErrs
+	}
+	revisionPath := fldPath.Child("revision")
+	if !revisionPattern.MatchString(content.Revision) {
+		allErrs = append(allErrs, field.Invalid(revisionPath, content.Revision,
+			"must be a directory name matching "+revisionPattern.String()))
+	}
+	if source := spec.Source; source != nil && source.S3 == nil && source.Archive == nil {
+		allErrs = append(allErrs, field.Forbidden(revisionPath, "requires an s3 or archive source, or no source"))
+	}
+	if mode := spec.Storage.Mode; mode == webv1beta1.StorageModeEmptyDir || mode == webv1beta1.StorageModeReadOnlyMany {
+		allErrs = append(allErrs, field.Forbidden(revisionPath, "cannot be used in "+mode+" mode"))
+	}
+	return allErrs
+}
+
+// validateRelease checks spec.release. The release stack gets a claim of its
+// own, filled from a source, and has to be reachable apart from the site.
+func validateRelease(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	release := spec.Release
+
+	if release.Source != nil {
+		allErrs = append(allErrs, validateSource(release.Source, fldPath.Child("source"))...)
+	} else if spec.Source == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("source"), "required when spec.source is not set"))
+	}
+	if release.ImageVersion != "" && !imageTagPattern.MatchString(release.ImageVersion) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("imageVersion"), release.ImageVersion,
+			"must be a valid image tag matching "+imageTagPattern.String()))
+	}
+
+	previewPath := fldPath.Child("preview")
+	preview := webv1beta1.RoutingSpec{Host: release.Preview.Host, Path: release.Preview.Path}
+	allErrs = append(allErrs, validateRouting(&preview, previewPath)...)
+	sameHost := preview.Host == "" || preview.Host == spec.Routing.Host
+	if sameHost && preview.Path != "" && preview.Path == spec.Routing.Path {
+		allErrs = append(allErrs, field.Invalid(previewPath.Child("path"), preview.Path,
+			"must differ from spec.routing.path unless a preview host is set"))
+	}
+
+	if spec.Storage.ExistingClaim != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cannot be used with spec.storage.existingClaim"))
+	}
+	if spec.Storage.Mode == webv1beta1.StorageModeReadOnlyMany {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cannot be used in readOnlyMany mode"))
+	}
+	return allErrs
+}
+
+// validateCanary checks spec.canary. On a claim the canary pods mount the
+// live claim next to the site pods, so a canary revision has to be one kept
+// on it.
+func validateCanary(spec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	canary := spec.Canary
+
+	if canary.ImageVersion == "" && canary.Revision == "" {
+		allErrs = append(allErrs, field.Required(fldPath, "imageVersion or revision is required"))
+	}
+	if canary.ImageVersion != "" && !imageTagPattern.MatchString(canary.ImageVersion) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("imageVersion"), canary.ImageVersion,
+			"must be a valid image tag matching "+imageTagPattern.String()))
+	}
+	if canary.Revision != "" {
+		revisionPath := fldPath.Child("revision")
+		if !revisionPattern.MatchString(canary.Revision) {
+			allErrs = append(allErrs, field.Invalid(revisionPath, canary.Revision,
+				"must be a directory name matching "+revisionPattern.String()))
+		}
+		if source := spec.Source; source != nil && source.S3 == nil && source.Archive == nil {
+			allErrs = append(allErrs, field.Forbidden(revisionPath, "requires an s3 or archive source, or no source"))
+		}
+		if mode := spec.Storage.Mode; mode == webv1beta1.StorageModeEmptyDir || mode == webv1beta1.StorageModeReadOnlyMany {
+			allErrs = append(allErrs, field.Forbidden(revisionPath, "cannot be used in "+mode+" mode"))
+		}
+		if spec.Server.Root != "" {
+			allErrs = append(allErrs, field.Forbidden(revisionPath, "cannot be used with spec.server.root"))
+		}
+	}
+	if canary.Replicas != nil && *canary.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), *canary.Replicas, "must be greater than or equal to 1"))
+	}
+	if p := canary.MaxErrorPercentage; p != nil && (*p < 0 || *p > 100) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxErrorPercentage"), *p, "must be between 0 and 100"))
+	}
+
+	stepsPath := fldPath.Child("steps")
+	if len(canary.Steps) == 0 {
+		allErrs = append(allErrs, field.Required(stepsPath, "at least one step is required"))
+	}
+	for i, step := range canary.Steps {
+		if step.Weight < 1 || step.Weight > 100 {
+			allErrs = append(allErrs, field.Invalid(stepsPath.Index(i).Child("weight"), step.Weight, "must be between 1 and 100"))
+		}
+		if step.Pause != nil && step.Pause.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(stepsPath.Index(i).Child("pause"), step.Pause.Duration.String(),
+				"must not be negative"))
+		}
+	}
+
+	if spec.Release != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cannot be combined with spec.release"))
+	}
+	if slices.Contains(spec.Storage.AccessModes, corev1.ReadWriteOncePod) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cannot share a ReadWriteOncePod claim with the site pods"))
+	}
+	return allErrs
+}
+
+// validateStorageUpdate rejects changes to the PVC fields Kubernetes does not
+// allow to change once the claim exists.
+func validateStorageUpdate(oldStorage, newStorage *webv1beta1.StorageSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if ptr.Deref(oldStorage.StorageClassName, "") != ptr.Deref(newStorage.StorageClassName, "") {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("storageClassName"),
+			"is immutable without spec.storage.allowMigration"))
+	}
+	if !slices.Equal(oldStorage.AccessModes, newStorage.AccessModes) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("accessModes"),
+			"is immutable without spec.storage.allowMigration"))
+	}
+	return allErrs
+}
+
+// validateSource checks the content source of the site.
+func validateSource(source *webv1beta1.SourceSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	set := 0
+	for _, configured := range []bool{
+		source.Git != nil, source.S3 != nil, source.Archive != nil, source.Image != nil, source.ConfigMapRef != nil,
+		source.SecretRef != nil,
+	} {
+		if configured {
+			set++
+		}
+	}
+	if set != 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath, set, "exactly one source must be set"))
+	}
+	if git := source.Git; git != nil {
+		gitPath := fldPath.Child("git")
+		if git.URL == "" {
+			allErrs = append(allErrs, field.Required(gitPath.Child("url"), ""))
+		} else if strings.ContainsAny(git.URL, " \t\n") {
+			allErrs = append(allErrs, field.Invalid(gitPath.Child("url"), git.URL, "must not contain whitespace"))
+		}
+		if git.Ref != "" && (strings.HasPrefix(git.Ref, "-") || strings.ContainsAny(git.Ref, " \t\n~^:?*[\\")) {
+			allErrs = append(allErrs, field.Invalid(gitPath.Child("ref"), git.Ref, "must be a valid git branch, tag or commit"))
+		}
+		allErrs = append(allErrs, validateRelativePath(git.Subdirectory, gitPath.Child("subdirectory"))...)
+		if ref := git.CredentialsSecretRef; ref != nil {
+			for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+				allErrs = append(allErrs, field.Invalid(gitPath.Child("credentialsSecretRef", "name"), ref.Name, msg))
+			}
+		}
+		if git.PollInterval != nil && git.PollInterval.Duration < time.Second {
+			allErrs = append(allErrs, field.Invalid(gitPath.Child("pollInterval"), git.PollInterval.Duration.String(),
+				"must be at least 1s"))
+		}
+	}
+	if s3 := source.S3; s3 != nil {
+		allErrs = append(allErrs, validateS3Source(s3, fldPath.Child("s3"))...)
+	}
+	if archive := source.Archive; archive != nil {
+		allErrs = append(allErrs, validateArchiveSource(archive, fldPath.Child("archive"))...)
+	}
+	if image := source.Image; image != nil {
+		allErrs = append(allErrs, validateImageSource(image, fldPath.Child("image"))...)
+	}
+	if ref := source.ConfigMapRef; ref != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("configMapRef", "name"), ref.Name, msg))
+		}
+	}
+	if ref := source.SecretRef; ref != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("secretRef", "name"), ref.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateS3Source checks the bucket location and sync interval of an S3 source.
+func validateS3Source(s3 *webv1beta1.S3Source, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if s3.Endpoint != "" {
+		if u, err := url.Parse(s3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("endpoint"), s3.Endpoint, "must be an http or https URL"))
+		}
+	}
+	if !bucketPattern.MatchString(s3.Bucket) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("bucket"), s3.Bucket,
+			"must be a valid bucket name matching "+bucketPattern.String()))
+	}
+	if strings.ContainsAny(s3.Prefix, "\x00\n") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("prefix"), s3.Prefix, "must not contain control characters"))
+	}
+	if ref := s3.CredentialsSecretRef; ref != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("credentialsSecretRef", "name"), ref.Name, msg))
+		}
+	}
+	if s3.SyncInterval != nil && s3.SyncInterval.Duration < time.Minute {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("syncInterval"), s3.SyncInterval.Duration.String(),
+			"must be at least 1m"))
+	}
+
+	return allErrs
+}
+
+// validateArchiveSource checks the URL, digest and strip count of an archive source.
+func validateArchiveSource(archive *webv1beta1.ArchiveSource, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if u, err := url.Parse(archive.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("url"), archive.URL, "must be an http or https URL"))
+	}
+	if !sha256Pattern.MatchString(archive.SHA256) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("sha256"), archive.SHA256, "must be 64 lowercase hex characters"))
+	}
+	if archive.StripComponents < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("stripComponents"), archive.StripComponents,
+			"must be greater than or equal to 0"))
+	}
+
+	return allErrs
+}
+
+// validateImageSource checks the reference, path, pull secrets and mount of an image source.
+func validateImageSource(image *webv1beta1.ImageSource, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if image.Reference == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("reference"), ""))
+	} else if strings.HasPrefix(image.Reference, "-") || strings.ContainsAny(image.Reference, " \t\n") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("reference"), image.Reference, "must be a valid image reference"))
+	}
+	if image.Path != "" {
+		if !path.IsAbs(image.Path) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), image.Path, "must be an absolute path"))
+		} else if !nginxArgPattern.MatchString(image.Path) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), image.Path, nginxArgMessage))
+		}
+	}
+	for i, secret := range image.PullSecrets {
+		for _, msg := range validation.IsDNS1123Subdomain(secret.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("pullSecrets").Index(i).Child("name"), secret.Name, msg))
+		}
+	}
+	switch image.Mount {
+	case "", webv1beta1.ImageMountCopy, webv1beta1.ImageMountVolume:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("mount"), image.Mount,
+			[]string{webv1beta1.ImageMountCopy, webv1beta1.ImageMountVolume}))
+	}
+
+	return allErrs
+}
+
+// validateRelativePath checks that p stays inside the directory it is relative to.
+func validateRelativePath(p string, fldPath *field.Path) field.ErrorList {
+	if p == "" {
+		return nil
+	}
+	if path.IsAbs(p) {
+		return field.ErrorList{field.Invalid(fldPath, p, "must be a relative path")}
+	}
+	if cleaned := path.Clean(p); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return field.ErrorList{field.Invalid(fldPath, p, "must not point outside its parent directory")}
+	}
+	if !nginxArgPattern.MatchString(p) {
+		return field.ErrorList{field.Invalid(fldPath, p, nginxArgMessage)}
+	}
+	return nil
+}
+
+// validateServer checks the values rendered into the nginx server block.
+func validateServer(server *webv1beta1.ServerSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if server.Root != "" {
+		if !path.IsAbs(server.Root) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("root"), server.Root, "must be an absolute path"))
+		} else if !nginxArgPattern.MatchString(server.Root) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("root"), server.Root, nginxArgMessage))
+		}
+	}
+	for i, index := range server.Index {
+		if !nginxArgPattern.MatchString(index) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("index").Index(i), index, nginxArgMessage))
+		}
+	}
+	for i, file := range server.TryFiles {
+		if !nginxArgPattern.MatchString(file) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("tryFiles").Index(i), file, nginxArgMessage))
+		}
+	}
+	if server.Gzip != nil {
+		gzipPath := fldPath.Child("gzip")
+		for i, mimeType := range server.Gzip.Types {
+			if !mimeTypePattern.MatchString(mimeType) {
+				allErrs = append(allErrs, field.Invalid(gzipPath.Child("types").Index(i), mimeType, "must be a MIME type such as text/css"))
+			}
+		}
+		if server.Gzip.MinLength != nil && *server.Gzip.MinLength < 0 {
+			allErrs = append(allErrs, field.Invalid(gzipPath.Child("minLength"), *server.Gzip.MinLength, "must be greater than or equal to 0"))
+		}
+	}
+	if server.Expires != "" && !expiresPattern.MatchString(server.Expires) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("expires"), server.Expires,
+			"must be off, max, epoch or a time such as 1h or 30d"))
+	}
+	for name, value := range server.Headers {
+		headerPath := fldPath.Child("headers").Key(name)
+		if !headerNamePattern.MatchString(name) {
+			allErrs = append(allErrs, field.Invalid(headerPath, name, "must be a valid HTTP header name"))
+		}
+		if controlCharPattern.MatchString(value) {
+			allErrs = append(allErrs, field.Invalid(headerPath, value, "must not contain control characters"))
+		}
+	}
+	if ref := server.SnippetRef; ref != nil {
+		refPath := fldPath.Child("snippetRef")
+		if ref.Name == "" {
+			allErrs = append(allErrs, field.Required(refPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+				allErrs = append(allErrs, field.Invalid(refPath.Child("name"), ref.Name, msg))
+			}
+		}
+		if ref.Key != "" {
+			for _, msg := range validation.IsConfigMapKey(ref.Key) {
+				allErrs = append(allErrs, field.Invalid(refPath.Child("key"), ref.Key, msg))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateRouting checks the host and path the site Ingress is built from.
+func validateRouting(routing *webv1beta1.RoutingSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if routing.Host != "" {
+		var msgs []string
+		if strings.HasPrefix(routing.Host, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(routing.Host)
+		} else {
+			msgs = validation.IsDNS1123Subdomain(routing.Host)
+		}
+		for _, msg := range msgs {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), routing.Host, msg))
+		}
+	}
+	if routing.Path != "" && !strings.HasPrefix(routing.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), routing.Path, "must start with '/'"))
+	}
+
+	gatewayPath := fldPath.Child("gateway")
+	switch routing.Type {
+	case "", webv1beta1.RoutingTypeIngress:
+		if routing.Gateway != nil {
+			allErrs = append(allErrs, field.Forbidden(gatewayPath, "only applies to gatewayAPI routing"))
+		}
+	case webv1beta1.RoutingTypeGatewayAPI:
+		if routing.IngressClassName != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("ingressClassName"), "only applies to ingress routing"))
+		}
+		gateway := routing.Gateway
+		if gateway == nil {
+			allErrs = append(allErrs, field.Required(gatewayPath, "required for gatewayAPI routing"))
+			break
+		}
+		if gateway.Name == "" {
+			allErrs = append(allErrs, field.Required(gatewayPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(gateway.Name) {
+				allErrs = append(allErrs, field.Invalid(gatewayPath.Child("name"), gateway.Name, msg))
+			}
+		}
+		if gateway.Namespace != "" {
+			for _, msg := range validation.IsDNS1123Label(gateway.Namespace) {
+				allErrs = append(allErrs, field.Invalid(gatewayPath.Child("namespace"), gateway.Namespace, msg))
+			}
+		}
+		if gateway.SectionName != "" {
+			for _, msg := range validation.IsDNS1123Subdomain(gateway.SectionName) {
+				allErrs = append(allErrs, field.Invalid(gatewayPath.Child("sectionName"), gateway.SectionName, msg))
+			}
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), routing.Type, []string{
+			webv1beta1.RoutingTypeIngress, webv1beta1.RoutingTypeGatewayAPI,
+		}))
+	}
+
+	return allErrs
+}
+
+// validateStorageResize rejects attempts to shrink the site PVC, which Kubernetes does not support.
+func validateStorageResize(oldSpec, newSpec *webv1beta1.NginxStaticSiteSpec, fldPath *field.Path) field.ErrorList {
+	oldSize, err := resource.ParseQuantity(oldSpec.Storage.Size)
+	if err != nil {
+		return nil
+	}
+	newSize, err := resource.ParseQuantity(newSpec.Storage.Size)
+	if err != nil {
+		return nil
+	}
+	if newSize.Cmp(oldSize) < 0 {
+		return field.ErrorList{field.Forbidden(fldPath,
+			fmt.Sprintf("shrinking the volume from %s to %s is not supported without spec.storage.allowMigration",
+				oldSpec.Storage.Size, newSpec.Storage.Size))}
+	}
+	return nil
+}
+
+// invalid wraps field errors in an Invalid status error so kubectl shows them per field.
+func invalid(site *webv1beta1.NginxStaticSite, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(webv1beta1.GroupVersion.WithKind("NginxStaticSite").GroupKind(), site.Name, allErrs)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	webv1beta1 "github.com/m-nik/k8s-nginx-operator/api/v1beta1"
+)
+
+func validSite() *webv1beta1.NginxStaticSite {
+	site := &webv1beta1.NginxStaticSite{ObjectMeta: metav1.ObjectMeta{Name: "docs", Namespace: "default"}}
+	site.Spec.Storage.Size = "1Gi"
+	site.Spec.Content.Path = "/usr/share/nginx/html"
+	site.Spec.Pod.ImageVersion = "1.27.0"
+	return site
+}
+
+func TestValidateUpdate(t *testing.T) {
+	// A site stored before the webhook checked the storage size.
+	legacy := validSite()
+	legacy.Spec.Storage.Size = "1Gb"
+
+	tests := []struct {
+		name    string
+		old     *webv1beta1.NginxStaticSite
+		update  func(*webv1beta1.NginxStaticSite)
+		wantErr bool
+	}{
+		{
+			name: "finalizer added to a legacy site",
+			old:  legacy,
+			update: func(site *webv1beta1.NginxStaticSite) {
+				site.Finalizers = []string{"nginxstaticsite.finalizers.ictplus.ir"}
+			},
+		},
+		{
+			name: "finalizer removed from a deleted legacy site",
+			old:  legacy,
+			update: func(site *webv1beta1.NginxStaticSite) {
+				site.DeletionTimestamp = &metav1.Time{}
+				site.Spec.Replicas = nil
+			},
+		},
+		{
+			name: "unrelated field changed on a legacy site",
+			old:  legacy,
+			update: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Pod.ImageVersion = "1.27.1"
+			},
+		},
+		{
+			name: "invalid field introduced",
+			old:  validSite(),
+			update: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Content.Path = "html"
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid field changed to another invalid value",
+			old:  legacy,
+			update: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Storage.Size = "2Gb"
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := tt.old.DeepCopy()
+			tt.update(site)
+			v := &NginxStaticSiteCustomValidator{}
+			_, err := v.ValidateUpdate(context.Background(), tt.old, site)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGitReplicas(t *testing.T) {
+	git := &webv1beta1.SourceSpec{Git: &webv1beta1.GitSource{URL: "https://example.com/site.git"}}
+	tests := []struct {
+		name    string
+		mutate  func(*webv1beta1.NginxStaticSite)
+		wantErr bool
+	}{
+		{
+			name:   "one replica on the PVC",
+			mutate: func(site *webv1beta1.NginxStaticSite) { site.Spec.Replicas = ptr.To[int32](1) },
+		},
+		{
+			name:    "two replicas on the PVC",
+			mutate:  func(site *webv1beta1.NginxStaticSite) { site.Spec.Replicas = ptr.To[int32](2) },
+			wantErr: true,
+		},
+		{
+			name: "autoscaled on the PVC",
+			mutate: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Autoscaling = &webv1beta1.AutoscalingSpec{MaxReplicas: 4}
+			},
+			wantErr: true,
+		},
+		{
+			name: "two replicas in emptyDir mode",
+			mutate: func(site *webv1beta1.NginxStaticSite) {
+				site.Spec.Replicas = ptr.To[int32](2)
+				site.Spec.Storage.Mode = webv1beta1.StorageModeEmptyDir
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := validSite()
+			site.Spec.Source = git.DeepCopy()
+			tt.mutate(site)
+			v := &NginxStaticSiteCustomValidator{}
+			_, err := v.ValidateCreate(context.Background(), site)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReadOnlyMany(t *testing.T) {
+	tests := []struct {
+		name          string
+		existingClaim string
+		wantErr       bool
+	}{
+		{name: "existing claim", existingClaim: "docs-content"},
+		{name: "no existing claim", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := validSite()
+			site.Spec.Storage.Mode = webv1beta1.StorageModeReadOnlyMany
+			site.Spec.Storage.ExistingClaim = tt.existingClaim
+			v := &NginxStaticSiteCustomValidator{}
+			_, err := v.ValidateCreate(context.Background(), site)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeMode    corev1.PersistentVolumeMode
+		existingClaim string
+		wantErr       bool
+	}{
+		{name: "filesystem", volumeMode: corev1.PersistentVolumeFilesystem},
+		{name: "block", volumeMode: corev1.PersistentVolumeBlock, wantErr: true},
+		{name: "with an existing claim", volumeMode: corev1.PersistentVolumeFilesystem, existingClaim: "docs-content", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := validSite()
+			site.Spec.Storage.VolumeMode = ptr.To(tt.volumeMode)
+			site.Spec.Storage.ExistingClaim = tt.existingClaim
+			v := &NginxStaticSiteCustomValidator{}
+			_, err := v.ValidateCreate(context.Background(), site)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -256,6 +257,85 @@ var _ = Describe("Manager", Ordered, func() {
 			))
 		})
 
+		It("should provisioned cert-manager", func() {
+			By("validating that cert-manager has the certificate Secret")
+			verifyCertManager := func(g Gomega) {
+				cmd := exec.Command("kubectl", "get", "secrets", "webhook-server-cert", "-n", namespace)
+				_, err := utils.Run(cmd)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			Eventually(verifyCertManager).Should(Succeed())
+		})
+
+		It("should have CA injection for mutating webhooks", func() {
+			By("checking CA injection for mutating webhooks")
+			verifyCAInjection := func(g Gomega) {
+				cmd := exec.Command("kubectl", "get",
+					"mutatingwebhookconfigurations.admissionregistration.k8s.io",
+					"nginxstaticsite-mutating-webhook-configuration",
+					"-o", "go-template={{ range .webhooks }}{{ .clientConfig.caBundle }}{{ end }}")
+				mwhOutput, err := utils.Run(cmd)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(len(mwhOutput)).To(BeNumerically(">", 10))
+			}
+			Eventually(verifyCAInjection).Should(Succeed())
+		})
+
+		It("should have CA injection for validating webhooks", func() {
+			By("checking CA injection for validating webhooks")
+			verifyCAInjection := func(g Gomega) {
+				cmd := exec.Command("kubectl", "get",
+					"validatingwebhookconfigurations.admissionregistration.k8s.io",
+					"nginxstaticsite-validating-webhook-configuration",
+					"-o", "go-template={{ range .webhooks }}{{ .clientConfig.caBundle }}{{ end }}")
+				vwhOutput, err := utils.Run(cmd)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(len(vwhOutput)).To(BeNumerically(">", 10))
+			}
+			Eventually(verifyCAInjection).Should(Succeed())
+		})
+
+		It("should have CA injection for NginxStaticSite conversion webhook", func() {
+			By("checking CA injection for NginxStaticSite conversion webhook")
+			verifyCAInjection := func(g Gomega) {
+				cmd := exec.Command("kubectl", "get",
+					"customresourcedefinitions.apiextensions.k8s.io",
+					"nginxstaticsites.web.ictplus.ir",
+					"-o", "go-template={{ .spec.conversion.webhook.clientConfig.caBundle }}")
+				vwhOutput, err := utils.Run(cmd)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(len(vwhOutput)).To(BeNumerically(">", 10))
+			}
+			Eventually(verifyCAInjection).Should(Succeed())
+		})
+
+		It("should reject an NginxStaticSite with an invalid spec", func() {
+			By("applying a site with a relative staticFilePath and an unparsable storageSize")
+			invalidSite := `
+apiVersion: web.ictplus.ir/v1alpha1
+kind: NginxStaticSite
+metadata:
+  name: invalid-site
+spec:
+  replicas: 1
+  storageSize: 1Gb
+  imageVersion: "1.25-alpine"
+  staticFilePath: html
+`
+			// The webhook may not be reachable right after the CA is injected, so retry
+			// until the request is rejected for the expected reasons.
+			verifyRejected := func(g Gomega) {
+				cmd := exec.Command("kubectl", "apply", "-n", namespace, "-f", "-")
+				cmd.Stdin = strings.NewReader(invalidSite)
+				_, err := utils.Run(cmd)
+				g.Expect(err).To(HaveOccurred())
+				// v1alpha1 requests are converted and validated as v1beta1.
+				g.Expect(err.Error()).To(ContainSubstring("spec.storage.size"))
+				g.Expect(err.Error()).To(ContainSubstring("spec.content.path"))
+			}
+			Eventually(verifyRejected).Should(Succeed())
+		})
+
 		// +kubebuilder:scaffold:e2e-webhooks-checks
 
 		// TODO: Customize the e2e test suite with scenarios specific to your project.